@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usagePlanFile = "Location of the plan made by the plan command"
+)
+
+func init() {
+	rootCmd.AddCommand(applyCommand)
+	applyCommand.Flags().StringVarP(&planFile, "file", "f", "", usagePlanFile)
+	applyCommand.MarkFlagRequired("file")
+	applyCommand.Flags().BoolVar(&continueOnError, "continue-on-error", false, usageContinueOnError)
+}
+
+var applyCommand = &cobra.Command{
+	Use:   "apply",
+	Short: "Execute the tag changes saved in a plan",
+	Long:  "Executes exactly the tag changes saved in a plan file. Nothing is changed if the tags of any resource in the plan changed since the plan was made.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plan, err := azure.NewPlanFromFile(planFile)
+		if err != nil {
+			return errors.Wrapf(err, "Can't read plan from %s", planFile)
+		}
+
+		if len(plan.Resources) == 0 {
+			fmt.Println("The plan has no changes")
+			return nil
+		}
+
+		sess, err := newSession()
+		if err != nil {
+			return err
+		}
+
+		tagger := newTagger(rules.TagRules{}, sess)
+
+		result, err := tagger.ApplyPlan(plan, func() error {
+			backupFile := azure.NewBackupFromPlan(plan, "")
+			fmt.Printf("Backup will be saved in: %s\n", backupFile)
+			return nil
+		})
+		printResult(result)
+
+		if err != nil {
+			return errors.Wrap(err, "can't apply plan")
+		}
+		return result.Err()
+	},
+}
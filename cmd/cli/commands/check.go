@@ -9,7 +9,6 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
-	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
 )
 
 const (
@@ -26,19 +25,22 @@ func init() {
 	rootCmd.AddCommand(checkCommand)
 	checkCommand.Flags().StringVarP(&resourceGroup, "rg", "r", "", usageResourceGroup)
 	checkCommand.MarkFlagRequired("rg")
+	checkCommand.Flags().StringVar(&fromSnapshot, "from-snapshot", "", "Check resources from a snapshot file instead of Azure")
 }
 
 var checkCommand = &cobra.Command{
 	Use:   "check",
 	Short: "Do sanity checks on a resource group (NOT FULLY IMPLEMENTED YET)",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		//sess, err := session.NewFromFile()
-		sess, err := session.NewFromAzureCredential(subscriptionId)
+		sess, err := newSession()
 		if err != nil {
-			return errors.Wrap(err, "could not create session")
+			return err
 		}
 
-		scanner := azure.NewResourceGroupScanner(sess)
+		scanner, err := newScanner(sess)
+		if err != nil {
+			return err
+		}
 		res, err := scanner.GetResourcesByResourceGroup(resourceGroup)
 		if err != nil {
 			return errors.Wrap(err, "could not get resources by group")
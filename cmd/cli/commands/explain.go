@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(explainCommand)
+	explainCommand.Flags().StringVarP(&mappingFile, "map", "m", "", usageMappingFile)
+	explainCommand.MarkFlagRequired("map")
+	explainCommand.Flags().StringVar(&fromSnapshot, "from-snapshot", "", "Explain a resource from a snapshot file instead of Azure")
+}
+
+var explainCommand = &cobra.Command{
+	Use:   "explain RESOURCE_ID",
+	Short: "Show why rules from a file match a resource or not",
+	Long:  "Evaluates every rule and every condition from a file on a single resource and prints the outcome of each condition with its parameters, the actions of the matched rules and the resulting tag changes. Nothing is executed.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		t, err := rules.NewFromFile(mappingFile)
+		if err != nil {
+			return errors.Wrapf(err, "Can't parse rules from %s", mappingFile)
+		}
+
+		sess, err := newSession()
+		if err != nil {
+			return err
+		}
+
+		// the resource is looked up in its own subscription and resource group
+		var scanner azure.Scanner = azure.NewResourceGroupScanner(sess.ForSubscription(azure.SubscriptionOf(id)))
+		if fromSnapshot != "" {
+			if scanner, err = azure.NewSnapshotScanner(fromSnapshot); err != nil {
+				return err
+			}
+		}
+		resource, err := azure.FindResource(scanner, id)
+		if err != nil {
+			return err
+		}
+
+		tagger := newTagger(t, sess)
+		azure.WriteExplanation(os.Stdout, tagger.Explain(resource), colorEnabled())
+		return nil
+	},
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usagePlanOut          = "Location where the plan will be saved"
+	usagePlanAllowPartial = "Plan actions even if some resource groups could not be scanned"
+)
+
+var (
+	planFile string
+)
+
+func init() {
+	rootCmd.AddCommand(planCommand)
+	planCommand.Flags().StringVarP(&mappingFile, "map", "m", "", usageMappingFile)
+	planCommand.MarkFlagRequired("map")
+	planCommand.Flags().StringVarP(&planFile, "out", "o", "tagmanager.plan.json", usagePlanOut)
+	planCommand.Flags().StringVar(&fromSnapshot, "from-snapshot", "", usageFromSnapshot)
+	planCommand.Flags().BoolVar(&allowPartial, "allow-partial", false, usagePlanAllowPartial)
+}
+
+var planCommand = &cobra.Command{
+	Use:   "plan",
+	Short: "Save the tag changes of rules from a file to a plan, without executing them",
+	Long:  "Evaluates rules from a file and saves the resulting tag changes of every resource to a plan file. The plan can be reviewed and executed later with apply.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := rules.NewFromFile(mappingFile)
+		if err != nil {
+			return errors.Wrapf(err, "Can't parse rules from %s", mappingFile)
+		}
+
+		sess, err := newSession()
+		if err != nil {
+			return err
+		}
+
+		tagger := newTagger(t, sess)
+		scanner, err := newScanner(tagger.Session)
+		if err != nil {
+			return err
+		}
+		res, err := scanner.GetResources()
+		if err := checkScan(err, true); err != nil {
+			return errors.Wrap(err, "can't scan resources")
+		}
+
+		tagger.EvaluateRules(res)
+
+		plan, err := tagger.Plan()
+		if err != nil {
+			return errors.Wrap(err, "can't make plan")
+		}
+
+		azure.WriteDiff(os.Stdout, plan, colorEnabled())
+
+		if err := plan.WriteFile(planFile); err != nil {
+			return errors.Wrap(err, "can't save plan")
+		}
+		fmt.Printf("Plan with [%d] resource(s) to change saved in: %s\n", len(plan.Resources), planFile)
+		return nil
+	},
+}
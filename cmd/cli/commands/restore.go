@@ -7,7 +7,6 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
-	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
 )
 
 const (
@@ -28,9 +27,9 @@ var restoreCommand = &cobra.Command{
 	Use:   "restore",
 	Short: "Restore previous tags from a file backup",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		sess, err := session.NewFromAzureCredential(subscriptionId)
+		sess, err := newSession()
 		if err != nil {
-			return errors.Wrap(err, "could not create session")
+			return err
 		}
 
 		fmt.Printf("Restoring tags from: [%s]\n", restoreFile)
@@ -5,7 +5,6 @@ import (
 
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
-	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -18,6 +17,7 @@ func init() {
 	resourceGroupTagCommand.Flags().BoolVar(&cleanTags, "cleantags", false, "Clean all tags before adding")
 	resourceGroupTagCommand.MarkFlagRequired("rg")
 	resourceGroupTagCommand.Flags().BoolVar(&dryRunEnabled, "dry", false, usageDryRun)
+	resourceGroupTagCommand.Flags().BoolVar(&continueOnError, "continue-on-error", false, usageContinueOnError)
 
 }
 
@@ -27,11 +27,17 @@ var resourceGroupTagCommand = &cobra.Command{
 	Long:  "Takes tags form a given resource group and applies them to all of the resources in the resource group. If any existing tags are already there, the new ones with be appended.",
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		sess, err := session.NewFromAzureCredential(subscriptionId)
+		sess, err := newSession()
 		if err != nil {
-			return errors.Wrap(err, "Could not create session")
+			return err
+		}
+		if !singleSubscription() {
+			return errors.New("retagrg works on a single subscription, select it with --subscription")
+		}
+		scanner, err := newScanner(sess)
+		if err != nil {
+			return err
 		}
-		scanner := azure.NewResourceGroupScanner(sess)
 
 		rgTags, err := scanner.GetResourceGroupTags(resourceGroup)
 
@@ -39,7 +45,10 @@ var resourceGroupTagCommand = &cobra.Command{
 			return errors.Wrap(err, "Can't get tags")
 		}
 
-		resources := scanner.ScanResourceGroup(resourceGroup)
+		resources, err := scanner.GetResourcesByResourceGroup(resourceGroup)
+		if err != nil {
+			return errors.Wrap(err, "Can't scan resource group")
+		}
 
 		var actions []rules.ActionItem
 
@@ -47,19 +56,20 @@ var resourceGroupTagCommand = &cobra.Command{
 			actions = append(actions, rules.ActionItem{"type": "cleanTags"})
 		}
 
+		// tags of the resource group are copied as they are, not expanded as templates
 		for key, tag := range rgTags {
-			actions = append(actions, rules.ActionItem{"type": "addTag", "tag": key, "value": *tag})
+			actions = append(actions, rules.ActionItem{"type": "addTag", "tag": rules.Literal(key), "value": rules.Literal(*tag)})
 		}
 
 		rules := rules.TagRules{Rules: []rules.Rule{
-			{Name: "name", Conditions: []rules.ConditionItem{
-				{"type": "rgEqual", "resourceGroup": resourceGroup},
+			{Name: "name", Conditions: []rules.Condition{
+				{ConditionItem: rules.ConditionItem{"type": "rgEqual", "resourceGroup": resourceGroup}},
 			},
 				Actions: actions,
 			},
 		}}
 
-		tagger := azure.NewTagger(rules, sess)
+		tagger := newTagger(rules, sess)
 		if dryRunEnabled {
 			tagger.DryRun()
 			fmt.Println("!! Running in a dry run mode")
@@ -69,29 +79,29 @@ var resourceGroupTagCommand = &cobra.Command{
 		tagger.EvaluateRules(resources)
 
 		fmt.Println("Evaluating conditions")
-		for _, i := range tagger.Matched {
+		for _, id := range tagger.MatchedIDs() {
+			i := tagger.Matched[id]
 			r := i.Resource
 			fmt.Printf("Conditions of [%d] rule(s) matched for [%s] in [%s] with ID %s\n", len(i.TagRules), *r.Name, *r.ResourceGroup, r.ID)
 		}
 
+		if len(tagger.Matched) > 0 && dryRunEnabled {
+			fmt.Println("\nTag changes on matched resources")
+			return printDiff(tagger)
+		}
+
 		if len(tagger.Matched) > 0 {
 			fmt.Println("\nExecuting actions on matched resources")
 			backupFile := azure.NewBackupFromMatched(tagger.Matched, "")
 			fmt.Printf("Backup will be saved in: %s\n", backupFile)
 
-			ael, err := tagger.ExecuteActions()
+			result, err := tagger.ExecuteActions()
+			printResult(result)
 
 			if err != nil {
 				return errors.Wrap(err, "can't execute actions")
 			}
-			fmt.Println("Executing actions")
-			for _, ae := range ael {
-				fmt.Printf("Rule [%s] on [%s]\n", ae.RuleName, ae.ResourceID)
-				for _, action := range ae.Actions {
-					fmt.Printf("Action: [%s] [%s = %s]\n", action.GetType(), action["tag"], action["value"])
-				}
-			}
-
+			return result.Err()
 		} else {
 			fmt.Println("No resources matched your conditions 😫")
 		}
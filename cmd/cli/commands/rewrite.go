@@ -5,19 +5,22 @@ import (
 
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
-	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 const (
-	usageMappingFile = "Location of the tag rules definition (json)"
-	usageDryRun      = "The tagger will not execute any actions"
+	usageMappingFile     = "Location of the tag rules definition (json)"
+	usageDryRun          = "The tagger will not execute any actions"
+	usageContinueOnError = "Keep executing actions on other resources when a resource fails"
+	usageAllowPartial    = "Execute actions even if some resource groups could not be scanned"
 )
 
 var (
-	mappingFile   string
-	dryRunEnabled bool
+	mappingFile     string
+	dryRunEnabled   bool
+	continueOnError bool
+	allowPartial    bool
 )
 
 func init() {
@@ -25,6 +28,9 @@ func init() {
 	rewriteCommand.Flags().StringVarP(&mappingFile, "map", "m", "", usageMappingFile)
 	rewriteCommand.MarkFlagRequired("map")
 	rewriteCommand.Flags().BoolVar(&dryRunEnabled, "dry", false, usageDryRun)
+	rewriteCommand.Flags().BoolVar(&continueOnError, "continue-on-error", false, usageContinueOnError)
+	rewriteCommand.Flags().BoolVar(&allowPartial, "allow-partial", false, usageAllowPartial)
+	rewriteCommand.Flags().StringVar(&fromSnapshot, "from-snapshot", "", usageFromSnapshot+", always in a dry run mode")
 }
 
 var rewriteCommand = &cobra.Command{
@@ -36,12 +42,15 @@ var rewriteCommand = &cobra.Command{
 			return errors.Wrapf(err, "Can't parse rules from %s", mappingFile)
 		}
 
-		sess, err := session.NewFromAzureCredential(subscriptionId)
+		sess, err := newSession()
 		if err != nil {
-			return errors.Wrap(err, "Could not create session")
+			return err
 		}
 
-		tagger := azure.NewTagger(t, sess)
+		tagger := newTagger(t, sess)
+		if fromSnapshot != "" {
+			dryRunEnabled = true
+		}
 		if dryRunEnabled {
 			tagger.DryRun()
 			fmt.Println("!! Running in a dry run mode")
@@ -52,38 +61,41 @@ var rewriteCommand = &cobra.Command{
 			return errors.Wrap(err, "Can't create tagger")
 		}
 
-		scanner := azure.NewResourceGroupScanner(tagger.Session)
-		res, err := scanner.GetResources()
+		scanner, err := newScanner(tagger.Session)
 		if err != nil {
+			return err
+		}
+		res, err := scanner.GetResources()
+		if err := checkScan(err, !dryRunEnabled); err != nil {
 			return errors.Wrap(err, "can't scan resources")
 		}
 
 		tagger.EvaluateRules(res)
 
 		fmt.Println("Evaluating conditions")
-		for _, i := range tagger.Matched {
+		for _, id := range tagger.MatchedIDs() {
+			i := tagger.Matched[id]
 			r := i.Resource
 			fmt.Printf("Conditions of [%d] rule(s) matched for [%s] in [%s] with ID %s\n", len(i.TagRules), *r.Name, *r.ResourceGroup, r.ID)
 		}
 
+		if len(tagger.Matched) > 0 && dryRunEnabled {
+			fmt.Println("\nTag changes on matched resources")
+			return printDiff(tagger)
+		}
+
 		if len(tagger.Matched) > 0 {
 			fmt.Println("\nExecuting actions on matched resources")
 			backupFile := azure.NewBackupFromMatched(tagger.Matched, "")
 			fmt.Printf("Backup will be saved in: %s\n", backupFile)
 
-			ael, err := tagger.ExecuteActions()
+			result, err := tagger.ExecuteActions()
+			printResult(result)
 
 			if err != nil {
 				return errors.Wrap(err, "can't exec actions")
 			}
-			fmt.Println("Executing actions")
-			for _, ae := range ael {
-				fmt.Printf("Rule [%s] on [%s]\n", ae.RuleName, ae.ResourceID)
-				for _, action := range ae.Actions {
-					fmt.Printf("Action: [%s] [%s = %s]\n", action.GetType(), action["tag"], action["value"])
-				}
-			}
-
+			return result.Err()
 		} else {
 			fmt.Println("No resources matched your conditions 😫")
 		}
@@ -3,15 +3,62 @@ package commands
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	azlog "github.com/Azure/azure-sdk-for-go/sdk/azcore/log"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+var (
+	verbose    bool
+	noColor    bool
+	workers    int
+	writeRate  float64
+	maxRetries int
+
+	subscriptions    []string
+	allSubscriptions bool
+	managementGroup  string
+	scannerType      string
+	graphFilter      string
+
+	cloudConfig = session.Config{
+		Cloud:         os.Getenv("AZURE_ENVIRONMENT"),
+		Endpoint:      os.Getenv("AZURE_ARM_ENDPOINT"),
+		AuthorityHost: os.Getenv("AZURE_AUTHORITY_HOST"),
+		Auth:          session.AuthConfigFromEnv(),
+	}
+)
+
+const (
+	scannerARM   = "arm"
+	scannerGraph = "graph"
+)
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output")
+	rootCmd.PersistentFlags().IntVar(&workers, "workers", azure.DefaultWorkers, "number of concurrent resource group scans and tag writes")
+	rootCmd.PersistentFlags().Float64Var(&writeRate, "write-rate", azure.DefaultWriteRate, "maximum tag writes per second, 0 for no limit")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", azure.Retry.MaxRetries, "number of retries of throttled or failed Azure calls")
+	rootCmd.PersistentFlags().StringSliceVarP(&subscriptions, "subscription", "s", nil, "subscriptions to scan, defaults to AZURE_SUBSCRIPTION_ID")
+	rootCmd.PersistentFlags().BoolVar(&allSubscriptions, "all-subscriptions", false, "scan all enabled subscriptions the credential can see")
+	rootCmd.PersistentFlags().StringVar(&managementGroup, "management-group", "", "scan all subscriptions in a management group")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Cloud, "cloud", cloudConfig.Cloud, "Azure cloud: AzurePublicCloud, AzureChinaCloud or AzureUSGovernmentCloud, defaults to AZURE_ENVIRONMENT or the public cloud")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Endpoint, "arm-endpoint", cloudConfig.Endpoint, "custom Azure Resource Manager endpoint, defaults to AZURE_ARM_ENDPOINT")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.AuthorityHost, "authority-host", cloudConfig.AuthorityHost, "custom Azure AD authority host, defaults to AZURE_AUTHORITY_HOST")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Auth.Method, "auth", session.AuthDefault, "authentication method: "+strings.Join(session.AuthMethods, ", "))
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Auth.TenantID, "tenant-id", cloudConfig.Auth.TenantID, "Azure AD tenant, defaults to AZURE_TENANT_ID")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Auth.ClientID, "client-id", cloudConfig.Auth.ClientID, "client ID of the service principal or user assigned managed identity, defaults to AZURE_CLIENT_ID")
+	rootCmd.PersistentFlags().StringVar(&cloudConfig.Auth.CertificatePath, "client-certificate", cloudConfig.Auth.CertificatePath, "certificate of --auth client-certificate, defaults to AZURE_CLIENT_CERTIFICATE_PATH")
+	rootCmd.PersistentFlags().StringVar(&scannerType, "scanner", scannerARM, "how resources are scanned, \"arm\" lists every resource group, \"graph\" queries Azure Resource Graph")
+	rootCmd.PersistentFlags().StringVar(&graphFilter, "filter", "", "KQL filter of resources scanned with --scanner graph, for example \"type =~ 'microsoft.compute/virtualmachines'\"")
 }
 
 var rootCmd = &cobra.Command{
@@ -19,7 +66,13 @@ var rootCmd = &cobra.Command{
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		if verbose {
 			log.SetLevel(log.InfoLevel)
+			// show which credentials of the chain are tried and why they fail
+			azlog.SetEvents(azidentity.EventAuthentication)
+			azlog.SetListener(func(event azlog.Event, msg string) {
+				log.Info(msg)
+			})
 		}
+		azure.Retry.MaxRetries = maxRetries
 	},
 }
 
@@ -30,3 +83,171 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+// colorEnabled returns true if the output should be colored, which is when the standard output is a terminal
+func colorEnabled() bool {
+	if noColor {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// printDiff prints the tag changes of matched resources without executing any action
+func printDiff(tagger *azure.Tagger) error {
+	plan, err := tagger.Plan()
+	if err != nil {
+		return errors.Wrap(err, "can't compute tag changes")
+	}
+	azure.WriteDiff(os.Stdout, plan, colorEnabled())
+	return nil
+}
+
+// printResult prints the executed actions and a summary of succeeded, skipped, unsupported and failed resources
+func printResult(result azure.ExecutionResult) {
+	fmt.Println("Executing actions")
+	for _, ae := range result.Executions() {
+		fmt.Printf("Rule [%s] on [%s]\n", ae.RuleName, ae.ResourceID)
+		for _, action := range ae.Actions {
+			fmt.Printf("Action: [%s] [%s = %s]\n", action.GetType(), action["tag"], action["value"])
+		}
+	}
+
+	for _, r := range result.Unsupported {
+		fmt.Printf("Unsupported: [%s] does not support tags\n", r.ResourceID)
+	}
+	for _, r := range result.Failed {
+		fmt.Printf("Failed: %s\n", r.Err)
+	}
+	fmt.Printf("\nSucceeded [%d], skipped [%d], unsupported [%d], failed [%d]\n",
+		len(result.Succeeded), len(result.Skipped), len(result.Unsupported), len(result.Failed))
+}
+
+// newSession creates a session in the cloud selected by the command line flags, which has no credentials
+// when resources are read from a snapshot
+func newSession() (*session.AzureSession, error) {
+	if fromSnapshot != "" {
+		return &session.AzureSession{}, nil
+	}
+	sess, err := session.NewFromAzureCredential(subscriptionId, cloudConfig)
+	return sess, errors.Wrap(err, "Could not create session")
+}
+
+// newTagger creates a tagger configured from the command line flags
+func newTagger(ruleDef rules.TagRules, sess *session.AzureSession) *azure.Tagger {
+	tagger := azure.NewTagger(ruleDef, sess)
+	tagger.SetWorkers(workers)
+	tagger.SetWriteRate(writeRate)
+	if continueOnError {
+		tagger.ContinueOnError()
+	}
+	return tagger
+}
+
+// newScanner creates a scanner of the snapshot or the subscriptions selected by the command line flags
+func newScanner(sess *session.AzureSession) (azure.Scanner, error) {
+	if fromSnapshot != "" {
+		return azure.NewSnapshotScanner(fromSnapshot)
+	}
+
+	switch scannerType {
+	case scannerGraph:
+		return newGraphScanner(sess)
+	case scannerARM:
+	default:
+		return nil, errors.Errorf("unknown scanner %q, use %q or %q", scannerType, scannerARM, scannerGraph)
+	}
+	if graphFilter != "" {
+		return nil, errors.New("--filter can only be used with --scanner graph")
+	}
+
+	subs, err := scanSubscriptions(sess)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(subs) == 1 {
+		scanner := azure.NewResourceGroupScanner(sess.ForSubscription(subs[0]))
+		scanner.Workers = workers
+		return scanner, nil
+	}
+
+	scanner := azure.NewSubscriptionsScanner(sess, subs)
+	for _, s := range scanner.Scanners {
+		s.Workers = workers
+	}
+	return scanner, nil
+}
+
+// newGraphScanner creates a Resource Graph scanner of the scope selected by the command line flags. Unlike the
+// ARM scanner it queries a management group or, with --all-subscriptions, the whole tenant directly.
+func newGraphScanner(sess *session.AzureSession) (azure.Scanner, error) {
+	scanner := azure.NewResourceGraphScanner(sess)
+	scanner.Filter = graphFilter
+
+	switch {
+	case managementGroup != "":
+		scanner.Subscriptions = nil
+		scanner.ManagementGroups = []string{managementGroup}
+	case allSubscriptions:
+		scanner.Subscriptions = nil
+	default:
+		subs, err := scanSubscriptions(sess)
+		if err != nil {
+			return nil, err
+		}
+		scanner.Subscriptions = subs
+	}
+	return scanner, nil
+}
+
+// singleSubscription returns true if the command line flags select at most one subscription
+func singleSubscription() bool {
+	return !allSubscriptions && managementGroup == "" && len(subscriptions) <= 1
+}
+
+// scanSubscriptions returns IDs of subscriptions selected by --all-subscriptions, --management-group or --subscription,
+// in this order, or the subscription of session sess
+func scanSubscriptions(sess *session.AzureSession) ([]string, error) {
+	var subs []string
+	var err error
+	switch {
+	case allSubscriptions:
+		subs, err = azure.ListSubscriptions(sess)
+	case managementGroup != "":
+		subs, err = azure.ListManagementGroupSubscriptions(sess, managementGroup)
+	case len(subscriptions) > 0:
+		subs = subscriptions
+	case sess.SubscriptionID != "":
+		subs = []string{sess.SubscriptionID}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "can't list subscriptions")
+	}
+
+	if len(subs) == 0 {
+		return nil, errors.New("no subscription to scan, set AZURE_SUBSCRIPTION_ID or use --subscription")
+	}
+	log.Infof("Scanning %d subscription(s)", len(subs))
+	return subs, nil
+}
+
+// checkScan returns err of a scan, unless the scan is only partial. Actions are never executed or planned on a partial
+// scan (writes is true) unless explicitly allowed, as rules like cleanTags must not be evaluated against an incomplete
+// inventory. Plans and snapshots are refused too, as apply writes plans without scanning again and plans can be made
+// from snapshots.
+func checkScan(err error, writes bool) error {
+	var partial *azure.PartialScanError
+	if !errors.As(err, &partial) {
+		return err
+	}
+
+	fmt.Printf("!! %s\n", partial)
+	if writes && !allowPartial {
+		return errors.New("refusing to use a partial scan, use --allow-partial to allow it")
+	}
+	return nil
+}
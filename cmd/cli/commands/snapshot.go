@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usageSnapshotOut          = "Location where the snapshot will be saved"
+	usageSnapshotFormat       = "Format of the snapshot, json or ndjson (by default from the file extension)"
+	usageFromSnapshot         = "Evaluate rules against resources from a snapshot file instead of Azure"
+	usageSnapshotAllowPartial = "Save the snapshot even if some resource groups could not be scanned"
+)
+
+var (
+	snapshotFile   string
+	snapshotFormat string
+	fromSnapshot   string
+)
+
+func init() {
+	rootCmd.AddCommand(snapshotCommand)
+	snapshotCommand.Flags().StringVarP(&snapshotFile, "out", "o", "tagmanager.snapshot.json", usageSnapshotOut)
+	snapshotCommand.Flags().StringVar(&snapshotFormat, "format", "", usageSnapshotFormat)
+	snapshotCommand.Flags().BoolVar(&allowPartial, "allow-partial", false, usageSnapshotAllowPartial)
+}
+
+var snapshotCommand = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save all scanned resources and their tags to a file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sess, err := newSession()
+		if err != nil {
+			return err
+		}
+
+		scanner, err := newScanner(sess)
+		if err != nil {
+			return err
+		}
+		res, err := scanner.GetResources()
+		if err := checkScan(err, true); err != nil {
+			return errors.Wrap(err, "can't scan resources")
+		}
+
+		if err := azure.WriteSnapshotFile(snapshotFile, res, snapshotFormat); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot of %d resource(s) saved in: %s\n", len(res), snapshotFile)
+		return nil
+	},
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usageTestFiles = "Files with more tests of the rules, only their tests section is used"
+)
+
+var (
+	testFiles []string
+)
+
+func init() {
+	rootCmd.AddCommand(testCommand)
+	testCommand.Flags().StringVarP(&mappingFile, "map", "m", "", usageMappingFile)
+	testCommand.MarkFlagRequired("map")
+	testCommand.Flags().StringSliceVarP(&testFiles, "tests", "t", nil, usageTestFiles)
+}
+
+var testCommand = &cobra.Command{
+	Use:   "test",
+	Short: "Run the tests of rules from a file against fixture resources",
+	Long:  "Evaluates rules from a file on the fixture resources of their tests and applies the actions in memory, without connecting to Azure. Every test passes if the matched rules and the resulting tags are the expected ones.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		t, err := rules.NewFromFile(mappingFile)
+		if err != nil {
+			return errors.Wrapf(err, "Can't parse rules from %s", mappingFile)
+		}
+		for _, f := range testFiles {
+			more, err := rules.NewFromFile(f)
+			if err != nil {
+				return errors.Wrapf(err, "Can't parse tests from %s", f)
+			}
+			t.Tests = append(t.Tests, more.Tests...)
+		}
+		if len(t.Tests) == 0 {
+			return errors.Errorf("no tests in %s, add a tests section or give files with --tests", mappingFile)
+		}
+
+		tagger := azure.NewTagger(t, &session.AzureSession{})
+		results := tagger.TestRules(t.Tests)
+		if failed := azure.WriteTestResults(os.Stdout, results, colorEnabled()); failed > 0 {
+			return errors.Errorf("%d of %d test(s) failed", failed, len(results))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usageValidateStrict = "Fail on warnings too, like unknown parameters"
+	usageValidateSchema = "Print the JSON Schema of rule files and exit"
+)
+
+var (
+	validateStrict bool
+	validateSchema bool
+)
+
+func init() {
+	rootCmd.AddCommand(validateCommand)
+	validateCommand.Flags().StringVarP(&mappingFile, "map", "m", "", usageMappingFile)
+	validateCommand.Flags().BoolVar(&validateStrict, "strict", false, usageValidateStrict)
+	validateCommand.Flags().BoolVar(&validateSchema, "schema", false, usageValidateSchema)
+}
+
+var validateCommand = &cobra.Command{
+	Use:          "validate",
+	Short:        "Check rules from a file without connecting to Azure",
+	Long:         "Checks that every condition and action of the rules from a file and the files it includes has a known type and all required parameters, that patterns and templates compile and that no unknown keys are used. Problems are printed with their file and line.",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if validateSchema {
+			schema, err := rules.JSONSchema()
+			if err != nil {
+				return errors.Wrap(err, "can't make schema")
+			}
+			fmt.Println(string(schema))
+			return nil
+		}
+		if mappingFile == "" {
+			return errors.New("required flag \"map\" not set")
+		}
+
+		t, issues, err := rules.LoadFile(mappingFile)
+		var verr *rules.ValidationError
+		if errors.As(err, &verr) {
+			issues = append(issues, verr.Issues...)
+		} else if err != nil {
+			return errors.Wrapf(err, "Can't parse rules from %s", mappingFile)
+		}
+
+		var errs, warnings int
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stderr, issue)
+			if issue.Warning {
+				warnings++
+			} else {
+				errs++
+			}
+		}
+		switch {
+		case errs > 0:
+			return errors.Errorf("%s has %d error(s) and %d warning(s)", mappingFile, errs, warnings)
+		case warnings > 0 && validateStrict:
+			return errors.Errorf("%s has %d warning(s)", mappingFile, warnings)
+		}
+		fmt.Printf("%s is valid: %d rule(s), %d warning(s)\n", mappingFile, len(t.Rules), warnings)
+		return nil
+	},
+}
@@ -0,0 +1,361 @@
+// Package armtest runs a local HTTPS server emulating the part of the Azure Resource Manager REST API used by
+// the tag manager, backed by a fake.Cloud. Sessions of the server use the real SDK clients, so paging, throttling
+// and failures of the real code paths can be tested without Azure. The server also emulates the token endpoint of
+// managed identities, so sessions authenticate with a real credential.
+package armtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/fake"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+)
+
+// DefaultPageSize is the number of items in a page of the list responses
+const DefaultPageSize = 2
+
+// Token is the access token required by the server, issued by its managed identity token endpoint
+const Token = "armtest-token"
+
+// tokenPath is the path of the managed identity token endpoint of the instance metadata service
+const tokenPath = "/metadata/identity/oauth2/token"
+
+var (
+	groupsPath    = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups$`)
+	groupPath     = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups/([^/]+)$`)
+	resourcesPath = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups/([^/]+)/resources$`)
+	resourcePath  = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourcegroups/[^/]+/providers/.+$`)
+	tagsSuffix    = regexp.MustCompile(`(?i)/providers/microsoft\.resources/tags/default$`)
+)
+
+// Server emulates ARM for the subscription of Cloud
+type Server struct {
+	*httptest.Server
+	Cloud    *fake.Cloud
+	PageSize int // number of items in a page of list responses
+	Polls    int // number of polls of a long-running operation before it succeeds
+
+	mu         sync.Mutex
+	throttles  []*throttle
+	operations map[string]int // remaining polls of long-running operations by ID
+	requests   []string
+}
+
+// throttle makes the next n requests matching method and path fail with 429
+type throttle struct {
+	method     string
+	path       string
+	n          int
+	retryAfter time.Duration
+}
+
+// NewServer starts a Server for cloud. It must be closed by the caller.
+func NewServer(cloud *fake.Cloud) *Server {
+	cloud.PageSize = 0 // pages are made by the server
+	s := &Server{
+		Cloud:      cloud,
+		PageSize:   DefaultPageSize,
+		Polls:      1,
+		operations: make(map[string]int),
+	}
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+// Session returns a session of the subscription of the server, whose clients call the server. Its managed identity
+// credential gets Token from the server.
+func (s *Server) Session() *session.AzureSession {
+	transport := transport{s}
+	cred, err := azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Transport: transport},
+	})
+	if err != nil {
+		panic(err) // a system assigned managed identity credential can always be created
+	}
+	return &session.AzureSession{
+		SubscriptionID: s.Cloud.SubscriptionID,
+		Credential:     cred,
+		Endpoint:       s.URL,
+		Transport:      transport,
+	}
+}
+
+// transport sends requests of SDK clients to the server, including the token requests of managed identities
+type transport struct {
+	s *Server
+}
+
+// Do sends request r to the server
+func (t transport) Do(r *http.Request) (*http.Response, error) {
+	if r.URL.Path == tokenPath {
+		u, err := url.Parse(t.s.URL)
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host, r.Host = u.Scheme, u.Host, u.Host
+	}
+	return t.s.Client().Do(r)
+}
+
+// Throttle makes the next n requests with method (any method when empty) to a path containing path fail with
+// 429 Too Many Requests, asking the client to retry after retryAfter
+func (s *Server) Throttle(method, path string, n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttles = append(s.throttles, &throttle{method: method, path: strings.ToLower(path), n: n, retryAfter: retryAfter})
+}
+
+// Requests returns all requests served so far as "METHOD path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// ServeHTTP serves a request of an ARM client
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	s.record(r)
+
+	if path == tokenPath {
+		s.token(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+Token {
+		writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "missing or invalid access token")
+		return
+	}
+	if retryAfter, ok := s.throttled(r); ok {
+		w.Header().Set("retry-after-ms", strconv.FormatInt(retryAfter.Milliseconds(), 10))
+		writeError(w, http.StatusTooManyRequests, "TooManyRequests", "too many requests, retry later")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(strings.ToLower(path), "/operations/"):
+		s.operation(w, r, path[len("/operations/"):])
+	case tagsSuffix.MatchString(path):
+		s.tags(w, r, tagsSuffix.ReplaceAllString(path, ""))
+	case groupsPath.MatchString(path) && r.Method == http.MethodGet:
+		s.groups(w, r)
+	case groupPath.MatchString(path) && r.Method == http.MethodGet:
+		s.group(w, r, groupPath.FindStringSubmatch(path)[1])
+	case resourcesPath.MatchString(path) && r.Method == http.MethodGet:
+		s.resources(w, r, resourcesPath.FindStringSubmatch(path)[1])
+	case resourcePath.MatchString(path):
+		s.resource(w, r, path)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", fmt.Sprintf("%s %s is not emulated", r.Method, path))
+	}
+}
+
+// token serves an access token to a managed identity
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Metadata") != "true" {
+		writeError(w, http.StatusBadRequest, "BadRequest", "required metadata header not specified")
+		return
+	}
+	writeResult(w, http.StatusOK, map[string]string{"access_token": Token, "expires_in": "3600", "token_type": "Bearer"}, nil)
+}
+
+// record records request r
+func (s *Server) record(r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+}
+
+// throttled returns true and the retry delay if request r has to be throttled
+func (s *Server) throttled(r *http.Request) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.throttles {
+		if t.n > 0 && (t.method == "" || t.method == r.Method) && strings.Contains(strings.ToLower(r.URL.Path), t.path) {
+			t.n--
+			return t.retryAfter, true
+		}
+	}
+	return 0, false
+}
+
+// tags serves the tags API of scope
+func (s *Server) tags(w http.ResponseWriter, r *http.Request, scope string) {
+	client := s.Cloud.TagsClient()
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := client.GetAtScope(r.Context(), scope, nil)
+		writeResult(w, http.StatusOK, resp.TagsResource, err)
+	case http.MethodPatch:
+		var patch armresources.TagsPatchResource
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		resp, err := client.UpdateAtScope(r.Context(), scope, patch, nil)
+		writeResult(w, http.StatusOK, resp.TagsResource, err)
+	case http.MethodDelete:
+		_, err := client.DeleteAtScope(r.Context(), scope, nil)
+		writeResult(w, http.StatusOK, nil, err)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// groups serves the list of resource groups
+func (s *Server) groups(w http.ResponseWriter, r *http.Request) {
+	var groups []*armresources.ResourceGroup
+	pager := s.Cloud.GroupsClient().NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(r.Context())
+		if err != nil {
+			writeResult(w, 0, nil, err)
+			return
+		}
+		groups = append(groups, page.Value...)
+	}
+
+	from, to, next := s.page(r, len(groups))
+	writeResult(w, http.StatusOK, armresources.ResourceGroupListResult{Value: groups[from:to], NextLink: next}, nil)
+}
+
+// group serves the resource group rg
+func (s *Server) group(w http.ResponseWriter, r *http.Request, rg string) {
+	resp, err := s.Cloud.GroupsClient().Get(r.Context(), rg, nil)
+	writeResult(w, http.StatusOK, resp.ResourceGroup, err)
+}
+
+// resources serves the list of resources in resource group rg
+func (s *Server) resources(w http.ResponseWriter, r *http.Request, rg string) {
+	var resources []*armresources.GenericResourceExpanded
+	pager := s.Cloud.ResourcesClient().NewListByResourceGroupPager(rg, nil)
+	for pager.More() {
+		page, err := pager.NextPage(r.Context())
+		if err != nil {
+			writeResult(w, 0, nil, err)
+			return
+		}
+		resources = append(resources, page.Value...)
+	}
+
+	from, to, next := s.page(r, len(resources))
+	writeResult(w, http.StatusOK, armresources.ResourceListResult{Value: resources[from:to], NextLink: next}, nil)
+}
+
+// resource serves GET of resource id and PATCH of its tags as a long-running operation
+func (s *Server) resource(w http.ResponseWriter, r *http.Request, id string) {
+	res := s.Cloud.Resource(id)
+	if res == nil {
+		writeError(w, http.StatusNotFound, "ResourceNotFound", fmt.Sprintf("resource %s not found", id))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeResult(w, http.StatusOK, res, nil)
+	case http.MethodPatch:
+		var update armresources.GenericResource
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		operation := armresources.TagsPatchOperationReplace
+		_, err := s.Cloud.TagsClient().UpdateAtScope(r.Context(), id, armresources.TagsPatchResource{
+			Operation:  &operation,
+			Properties: &armresources.Tags{Tags: update.Tags},
+		}, nil)
+		if err != nil {
+			writeResult(w, 0, nil, err)
+			return
+		}
+
+		s.mu.Lock()
+		opID := strconv.Itoa(len(s.operations) + 1)
+		s.operations[opID] = s.Polls
+		s.mu.Unlock()
+
+		w.Header().Set("Azure-AsyncOperation", fmt.Sprintf("%s/operations/%s?api-version=%s", s.URL, opID, r.URL.Query().Get("api-version")))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// operation serves the status of long-running operation id, which is in progress for the first Polls polls
+func (s *Server) operation(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	remaining, ok := s.operations[id]
+	if ok && remaining > 0 {
+		s.operations[id] = remaining - 1
+	}
+	s.mu.Unlock()
+
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "OperationNotFound", fmt.Sprintf("operation %s not found", id))
+	case remaining > 0:
+		writeResult(w, http.StatusOK, map[string]string{"status": "InProgress"}, nil)
+	default:
+		writeResult(w, http.StatusOK, map[string]string{"status": "Succeeded"}, nil)
+	}
+}
+
+// page returns the range of a page of n items requested by r and the link to the next page
+func (s *Server) page(r *http.Request, n int) (int, int, *string) {
+	from, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+	if from > n {
+		from = n
+	}
+	to := n
+	if s.PageSize > 0 && from+s.PageSize < n {
+		to = from + s.PageSize
+	}
+	if to == n {
+		return from, to, nil
+	}
+	next := fmt.Sprintf("%s%s?api-version=%s&$skiptoken=%d", s.URL, r.URL.Path, r.URL.Query().Get("api-version"), to)
+	return from, to, &next
+}
+
+// writeResult writes v as JSON with status, or the ARM error of err
+func writeResult(w http.ResponseWriter, status int, v interface{}, err error) {
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if errors.As(err, &respErr) {
+			writeError(w, respErr.StatusCode, respErr.ErrorCode, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, "InternalServerError", err.Error())
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if v != nil {
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// writeError writes an ARM error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-ms-error-code", code)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
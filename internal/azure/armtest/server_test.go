@@ -0,0 +1,91 @@
+package armtest
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/fake"
+	"github.com/pkg/errors"
+)
+
+func TestServer_updateByID(t *testing.T) {
+	cloud := fake.New("sub")
+	cloud.AddGroup("rg", "westeurope", nil)
+	id := cloud.AddResource("rg", "Microsoft.Storage/storageAccounts", "sa", "westeurope", map[string]string{"env": "dev"})
+
+	server := NewServer(cloud)
+	defer server.Close()
+	server.Polls = 2
+
+	sess := server.Session()
+	client, err := armresources.NewClient(sess.SubscriptionID, sess.Credential, sess.ClientOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := "prod"
+	poller, err := client.BeginUpdateByID(context.Background(), id, "2021-04-01", armresources.GenericResource{Tags: map[string]*string{"env": &env}}, nil)
+	if err != nil {
+		t.Fatalf("BeginUpdateByID() error = %v", err)
+	}
+	resp, err := poller.PollUntilDone(context.Background(), &runtime.PollUntilDoneOptions{Frequency: time.Millisecond})
+	if err != nil {
+		t.Fatalf("PollUntilDone() error = %v", err)
+	}
+	if resp.Tags["env"] == nil || *resp.Tags["env"] != "prod" {
+		t.Errorf("PollUntilDone() tags = %v, want env = prod", resp.Tags)
+	}
+
+	polls, tokens := 0, 0
+	for _, req := range server.Requests() {
+		switch {
+		case strings.HasPrefix(req, "GET /operations/"):
+			polls++
+		case strings.HasPrefix(req, "GET "+tokenPath):
+			tokens++
+		}
+	}
+	if polls != 3 {
+		t.Errorf("operation polled %d times, want 3", polls)
+	}
+	if tokens != 1 {
+		t.Errorf("token requested %d times, want once", tokens)
+	}
+
+	got, err := client.GetByID(context.Background(), id, "2021-04-01", nil)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if want := map[string]string{"env": "prod"}; !reflect.DeepEqual(cloud.Tags(*got.ID), want) {
+		t.Errorf("tags = %v, want %v", cloud.Tags(*got.ID), want)
+	}
+}
+
+func TestServer_unauthorized(t *testing.T) {
+	server := NewServer(fake.New("sub"))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/subscriptions/sub/resourcegroups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	sess := server.Session()
+	client, _ := armresources.NewResourceGroupsClient("sub", sess.Credential, sess.ClientOptions())
+	_, err = client.Get(context.Background(), "missing", nil)
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusNotFound || respErr.ErrorCode != "ResourceGroupNotFound" {
+		t.Errorf("Get() error = %v, want 404 ResourceGroupNotFound", err)
+	}
+}
@@ -25,9 +25,9 @@ type Restorer interface {
 
 // TagRestorer represents a restorer of Azure tags from backup
 type TagRestorer struct {
-	Session         *session.AzureSession // session to connect to Azure
-	ResourcesClient *armresources.Client  // client to the resources API
-	Backup          []BackupEntry         // list of backup entries
+	Session    *session.AzureSession // session to connect to Azure
+	TagsClient TagsAPI               // client to the tags API
+	Backup     []BackupEntry         // list of backup entries
 }
 
 // NewBackupFromMatched makes a file backup from the resources in matched to a json file in directory
@@ -41,6 +41,23 @@ func NewBackupFromMatched(matched map[string]Matched, directory string) string {
 		}
 		backup = append(backup, *entry)
 	}
+	return writeBackup(backup, directory)
+}
+
+// NewBackupFromPlan makes a file backup of the current tags of the resources in plan to a json file in directory
+func NewBackupFromPlan(plan Plan, directory string) string {
+	var backup []BackupEntry
+
+	for _, planned := range plan.Resources {
+		backup = append(backup, BackupEntry{
+			ID:   planned.ID,
+			Tags: planned.Current,
+		})
+	}
+	return writeBackup(backup, directory)
+}
+
+func writeBackup(backup []BackupEntry, directory string) string {
 	tmpfile, err := ioutil.TempFile(directory, "tagmanager.*.json")
 	if err != nil {
 		log.Fatal(err)
@@ -61,18 +78,14 @@ func NewBackupFromMatched(matched map[string]Matched, directory string) string {
 func (t TagRestorer) Restore() error {
 	for _, backupEntry := range t.Backup {
 		log.Infof("Restoring tags for [%s]\n", backupEntry.ID)
-		_, err := t.ResourcesClient.GetByID(context.Background(), backupEntry.ID, "2021-04-01", nil)
-
+		current, err := getTagsAtScope(context.Background(), t.TagsClient, backupEntry.ID)
 		if err != nil {
-			return errors.Wrap(err, "cannot get resource by id")
+			return errors.Wrapf(err, "cannot get tags of resource %s", backupEntry.ID)
 		}
 
-		genericResource := armresources.GenericResource{
-			Tags: backupEntry.Tags,
-		}
-		_, err = t.ResourcesClient.BeginUpdateByID(context.Background(), backupEntry.ID, "2021-04-01", genericResource, nil)
+		err = updateTagsAtScope(context.Background(), t.TagsClient, backupEntry.ID, current, backupEntry.Tags, nil)
 		if err != nil {
-			return errors.Wrapf(err, "cannot update resource %s by id", backupEntry.ID)
+			return errors.Wrapf(err, "cannot update tags of resource %s", backupEntry.ID)
 		}
 	}
 	return nil
@@ -81,7 +94,7 @@ func (t TagRestorer) Restore() error {
 // NewRestorerFromFile creates a TagRestorer, which will restore tag backup from filename
 func NewRestorerFromFile(filename string, s *session.AzureSession) *TagRestorer {
 
-	resClient, _ := armresources.NewClient(s.SubscriptionID, s.Credential, nil)
+	tagsClient, _ := armresources.NewTagsClient(s.SubscriptionID, s.Credential, s.ClientOptions())
 
 	var backup []BackupEntry
 	dat, err := ioutil.ReadFile(filename)
@@ -94,9 +107,9 @@ func NewRestorerFromFile(filename string, s *session.AzureSession) *TagRestorer
 	}
 
 	restorer := &TagRestorer{
-		Session:         s,
-		ResourcesClient: resClient,
-		Backup:          backup,
+		Session:    s,
+		TagsClient: tagsClient,
+		Backup:     backup,
 	}
 	return restorer
 }
@@ -0,0 +1,35 @@
+package azure
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// The interfaces below are the parts of the ARM clients the tool uses. They are implemented by the
+// clients of armresources and by the in-memory fakes of package fake.
+
+// TagsAPI reads and writes tags of any scope, implemented by *armresources.TagsClient
+type TagsAPI interface {
+	GetAtScope(ctx context.Context, scope string, options *armresources.TagsClientGetAtScopeOptions) (armresources.TagsClientGetAtScopeResponse, error)
+	UpdateAtScope(ctx context.Context, scope string, parameters armresources.TagsPatchResource, options *armresources.TagsClientUpdateAtScopeOptions) (armresources.TagsClientUpdateAtScopeResponse, error)
+	DeleteAtScope(ctx context.Context, scope string, options *armresources.TagsClientDeleteAtScopeOptions) (armresources.TagsClientDeleteAtScopeResponse, error)
+}
+
+// ResourcesAPI lists resources of a resource group, implemented by *armresources.Client
+type ResourcesAPI interface {
+	NewListByResourceGroupPager(resourceGroupName string, options *armresources.ClientListByResourceGroupOptions) *runtime.Pager[armresources.ClientListByResourceGroupResponse]
+}
+
+// GroupsAPI lists and reads resource groups, implemented by *armresources.ResourceGroupsClient
+type GroupsAPI interface {
+	NewListPager(options *armresources.ResourceGroupsClientListOptions) *runtime.Pager[armresources.ResourceGroupsClientListResponse]
+	Get(ctx context.Context, resourceGroupName string, options *armresources.ResourceGroupsClientGetOptions) (armresources.ResourceGroupsClientGetResponse, error)
+}
+
+var (
+	_ TagsAPI      = (*armresources.TagsClient)(nil)
+	_ ResourcesAPI = (*armresources.Client)(nil)
+	_ GroupsAPI    = (*armresources.ResourceGroupsClient)(nil)
+)
@@ -0,0 +1,176 @@
+package azure
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// TagConflict is a tag of a resource set to different values by two matched rules, or set by one rule and
+// removed by another. The tag ends with the value of Winner, chosen by the conflict policy of the rules.
+type TagConflict struct {
+	Key    string     `json:"key"`
+	Rules  [2]string  `json:"rules"`  // the rule which wrote the tag first and the rule which wrote it again
+	Values [2]*string `json:"values"` // values written by Rules, nil if the rule removed the tag
+	Winner string     `json:"winner"`
+	Policy string     `json:"policy"`
+}
+
+// String describes the conflict without its outcome
+func (c TagConflict) String() string {
+	return fmt.Sprintf("tag %s: rule %q %s, rule %q %s", c.Key, c.Rules[0], describeWrite(c.Values[0]), c.Rules[1], describeWrite(c.Values[1]))
+}
+
+// conflictLine formats a conflict with the winning rule as a line of a diff
+func conflictLine(c TagConflict, paint func(c, s string) string) string {
+	return paint(colorRed, "! conflict on "+c.String()) + " " + paint(colorFaint, fmt.Sprintf("(%s: %q wins)", c.Policy, c.Winner))
+}
+
+// logConflicts logs the conflicts of resource id as warnings
+func logConflicts(id string, conflicts []TagConflict) {
+	for _, c := range conflicts {
+		log.Warnf("Conflict on [%s] %s, rule %q wins (%s)", id, c, c.Winner, c.Policy)
+	}
+}
+
+// describeWrite describes writing value to a tag
+func describeWrite(value *string) string {
+	if value == nil {
+		return "removes it"
+	}
+	return fmt.Sprintf("sets %q", *value)
+}
+
+// tagWriter is a matched rule which wrote a tag, index is its position among the matched rules and value
+// the value it wrote, nil if it removed the tag
+type tagWriter struct {
+	rule  rules.Rule
+	index int
+	value *string
+}
+
+// actionRun applies the actions of the matched rules of a resource, keeping the rule which wrote every tag
+// and resolving the conflicts between rules with the conflict policy of the tagger
+type actionRun struct {
+	t         *Tagger
+	data      *Resource
+	policy    string
+	writers   map[string]tagWriter
+	conflicts []TagConflict
+}
+
+func (t *Tagger) newActionRun(data *Resource) *actionRun {
+	return &actionRun{t: t, data: data, policy: t.Rules.ConflictPolicy(), writers: make(map[string]tagWriter)}
+}
+
+// apply executes action of rule, the index-th matched rule, on the resource and returns it with its templates
+// expanded. Every tag the action writes is recorded, even if it already had the written value. A tag written
+// before with another value by another rule is a conflict: the value of the losing rule is reverted, or an
+// error is returned if the policy does not pick a winner.
+func (r *actionRun) apply(index int, rule rules.Rule, action rules.ActionItem) (rules.ActionItem, error) {
+	expanded, err := r.t.expandAction(r.data, action)
+	if err != nil {
+		return nil, err
+	}
+	writes := tagWrites(expanded, r.data.Tags)
+	if err := r.t.execute(r.data, expanded); err != nil {
+		return expanded, err
+	}
+
+	keys := make([]string, 0, len(writes))
+	for key := range writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		writer := tagWriter{rule: rule, index: index, value: writes[key]}
+		prev, ok := r.writers[key]
+		switch {
+		case !ok || prev.index == index:
+			r.writers[key] = writer
+			continue
+		case equalValue(prev.value, writer.value):
+			continue
+		}
+
+		c := TagConflict{
+			Key:    key,
+			Rules:  [2]string{prev.rule.Name, rule.Name},
+			Values: [2]*string{prev.value, writer.value},
+			Policy: r.policy,
+		}
+		winner, err := r.resolve(prev, writer)
+		if err != nil {
+			return expanded, errors.Wrapf(err, "conflict on %s", c)
+		}
+		if winner.index == prev.index {
+			if prev.value == nil {
+				delete(r.data.Tags, key)
+			} else {
+				r.data.Tags[key] = prev.value
+			}
+		}
+		c.Winner = winner.rule.Name
+		r.writers[key] = winner
+		r.conflicts = append(r.conflicts, c)
+	}
+	return expanded, nil
+}
+
+// tagWrites returns the tags action p writes on tags, with nil values for removed tags
+func tagWrites(p rules.ActionItem, tags map[string]*string) map[string]*string {
+	switch p.GetType() {
+	case "addTag":
+		value := p["value"]
+		return map[string]*string{p["tag"]: &value}
+	case "delTag":
+		return map[string]*string{p["tag"]: nil}
+	case "cleanTags":
+		writes := make(map[string]*string, len(tags))
+		for key := range tags {
+			writes[key] = nil
+		}
+		return writes
+	}
+	return nil
+}
+
+// equalValue returns true if a and b are both nil or point to equal values
+func equalValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// resolve returns which of the rules writing a tag wins by the conflict policy, prev wrote the tag before next
+func (r *actionRun) resolve(prev, next tagWriter) (tagWriter, error) {
+	switch r.policy {
+	case rules.ConflictFirstWins:
+		return prev, nil
+	case rules.ConflictPriority:
+		switch {
+		case next.rule.Priority > prev.rule.Priority:
+			return next, nil
+		case next.rule.Priority < prev.rule.Priority:
+			return prev, nil
+		}
+		return tagWriter{}, errors.Errorf("the rules have the same priority %d", next.rule.Priority)
+	case rules.ConflictError:
+		return tagWriter{}, errors.New("conflicts are not allowed")
+	}
+	return next, nil
+}
+
+// origins returns the name of the rule which wrote each tag
+func (r *actionRun) origins() map[string]string {
+	origins := make(map[string]string, len(r.writers))
+	for key, w := range r.writers {
+		origins[key] = w.rule.Name
+	}
+	return origins
+}
@@ -0,0 +1,83 @@
+package azure
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+func TestTagger_applyActionsConflicts(t *testing.T) {
+	matchedRules := func(priority int) []rules.Rule {
+		return []rules.Rule{
+			{Name: "default", Actions: []rules.ActionItem{
+				{"type": "addTag", "tag": "owner", "value": "team-a"},
+				{"type": "addTag", "tag": "env", "value": "dev"},
+				{"type": "addTag", "tag": "managed", "value": "yes"},
+			}},
+			{Name: "storage", Priority: priority, Actions: []rules.ActionItem{
+				{"type": "addTag", "tag": "owner", "value": "team-storage"},
+				{"type": "delTag", "tag": "env"},
+				{"type": "addTag", "tag": "managed", "value": "yes"},
+			}},
+		}
+	}
+	conflicts := func(winner, policy string) []TagConflict {
+		return []TagConflict{
+			{Key: "owner", Rules: [2]string{"default", "storage"}, Values: [2]*string{String("team-a"), String("team-storage")}, Winner: winner, Policy: policy},
+			{Key: "env", Rules: [2]string{"default", "storage"}, Values: [2]*string{String("dev"), nil}, Winner: winner, Policy: policy},
+		}
+	}
+	lastWins := map[string]*string{"owner": String("team-storage"), "managed": String("yes")}
+	firstWins := map[string]*string{"owner": String("team-a"), "env": String("dev"), "managed": String("yes")}
+
+	tests := []struct {
+		name          string
+		policy        string
+		priority      int
+		tags          map[string]*string
+		wantTags      map[string]*string
+		wantConflicts []TagConflict
+		wantErr       string
+	}{
+		{name: "default policy", policy: "", wantTags: lastWins, wantConflicts: conflicts("storage", rules.ConflictLastWins)},
+		{name: "last-wins", policy: rules.ConflictLastWins, wantTags: lastWins, wantConflicts: conflicts("storage", rules.ConflictLastWins)},
+		{name: "first-wins", policy: rules.ConflictFirstWins, wantTags: firstWins, wantConflicts: conflicts("default", rules.ConflictFirstWins)},
+		{name: "priority", policy: rules.ConflictPriority, priority: 10, wantTags: lastWins, wantConflicts: conflicts("storage", rules.ConflictPriority)},
+		{name: "priority with equal priorities", policy: rules.ConflictPriority,
+			wantErr: `rule "storage": conflict on tag owner: rule "default" sets "team-a", rule "storage" sets "team-storage": the rules have the same priority 0`},
+		{name: "error", policy: rules.ConflictError, priority: 10,
+			wantErr: `rule "storage": conflict on tag owner: rule "default" sets "team-a", rule "storage" sets "team-storage": conflicts are not allowed`},
+		{name: "first-wins with the value of the first rule already set", policy: rules.ConflictFirstWins,
+			tags: map[string]*string{"owner": String("team-a")}, wantTags: firstWins, wantConflicts: conflicts("default", rules.ConflictFirstWins)},
+		{name: "error with the value of the first rule already set", policy: rules.ConflictError,
+			tags:    map[string]*string{"owner": String("team-a")},
+			wantErr: `rule "storage": conflict on tag owner: rule "default" sets "team-a", rule "storage" sets "team-storage": conflicts are not allowed`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tagger := &Tagger{Rules: rules.TagRules{Conflicts: tt.policy}}
+			tagger.InitActionMap()
+
+			resource := Resource{ID: "1", Tags: copyTags(tt.tags)}
+			_, got, err := tagger.applyActions(&resource, matchedRules(tt.priority))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyActions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyActions() error = %v", err)
+			}
+			if !equalTags(resource.Tags, tt.wantTags) {
+				t.Errorf("applyActions() tags = %v, want %v", resource.Tags, tt.wantTags)
+			}
+			if !reflect.DeepEqual(got, tt.wantConflicts) {
+				t.Errorf("applyActions() conflicts = %+v, want %+v", got, tt.wantConflicts)
+			}
+		})
+	}
+}
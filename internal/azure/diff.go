@@ -0,0 +1,98 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorFaint  = "\033[2m"
+)
+
+// DiffSummary counts the tag changes of a plan
+type DiffSummary struct {
+	Resources int
+	Added     int
+	Changed   int
+	Removed   int
+	Conflicts int
+}
+
+// WriteDiff writes the tag changes of every resource in plan to w as a diff, followed by a summary.
+// Added tags are prefixed with +, changed with ~ and removed with -, conflicts between rules with !.
+// Resources of the plan whose tags do not change are listed with their conflicts.
+// If color is true, ANSI colors are used.
+func WriteDiff(w io.Writer, plan Plan, color bool) DiffSummary {
+	paint := painter(color)
+
+	summary := DiffSummary{Resources: len(plan.Resources)}
+	for _, planned := range plan.Resources {
+		fmt.Fprintf(w, "%s %s\n", paint(colorYellow, "~"), planned.ID)
+		for _, change := range planned.Changes {
+			switch change.Action {
+			case TagAdded:
+				summary.Added++
+			case TagChanged:
+				summary.Changed++
+			case TagRemoved:
+				summary.Removed++
+			}
+			fmt.Fprintf(w, "    %s\n", changeLine(change, paint))
+		}
+		for _, c := range planned.Conflicts {
+			summary.Conflicts++
+			fmt.Fprintf(w, "    %s\n", conflictLine(c, paint))
+		}
+	}
+	for _, planned := range plan.Conflicts {
+		fmt.Fprintf(w, "%s %s\n", paint(colorRed, "!"), planned.ID)
+		for _, c := range planned.Conflicts {
+			summary.Conflicts++
+			fmt.Fprintf(w, "    %s\n", conflictLine(c, paint))
+		}
+	}
+
+	if summary.Resources == 0 {
+		if len(plan.Conflicts) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "No tags will change")
+	} else {
+		fmt.Fprintf(w, "\n%d resource(s) to change: %s, %s, %s\n", summary.Resources,
+			paint(colorGreen, fmt.Sprintf("%d to add", summary.Added)),
+			paint(colorYellow, fmt.Sprintf("%d to change", summary.Changed)),
+			paint(colorRed, fmt.Sprintf("%d to remove", summary.Removed)))
+	}
+	if summary.Conflicts > 0 {
+		fmt.Fprintln(w, paint(colorRed, fmt.Sprintf("%d conflict(s) between rules", summary.Conflicts)))
+	}
+	return summary
+}
+
+// changeLine formats a tag change as a line of a diff, followed by the rule which caused it
+func changeLine(change TagChange, paint func(c, s string) string) string {
+	var line string
+	switch change.Action {
+	case TagAdded:
+		line = paint(colorGreen, fmt.Sprintf("+ %s = %s", change.Key, tagValue(change.NewValue)))
+	case TagChanged:
+		line = paint(colorYellow, fmt.Sprintf("~ %s: %s -> %s", change.Key, tagValue(change.OldValue), tagValue(change.NewValue)))
+	case TagRemoved:
+		line = paint(colorRed, fmt.Sprintf("- %s", change.Key))
+	}
+	return line + " " + paint(colorFaint, fmt.Sprintf("(rule: %s)", change.Rule))
+}
+
+// painter returns a function which colors s with ANSI color c, or returns it as it is if color is false
+func painter(color bool) func(c, s string) string {
+	return func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+}
@@ -0,0 +1,65 @@
+package azure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteDiff(t *testing.T) {
+	plan := Plan{Resources: []PlannedResource{{
+		ID: "1",
+		Changes: []TagChange{
+			{Key: "env", Action: TagChanged, OldValue: String("dev"), NewValue: String("prod"), Rule: "prod"},
+			{Key: "legacy", Action: TagRemoved, OldValue: String("yes"), Rule: "cleanup"},
+			{Key: "owner", Action: TagAdded, NewValue: String("team-a"), Rule: "cleanup"},
+		},
+		Conflicts: []TagConflict{
+			{Key: "env", Rules: [2]string{"dev", "prod"}, Values: [2]*string{String("dev"), String("prod")}, Winner: "prod", Policy: "last-wins"},
+		},
+	}}}
+
+	var buf bytes.Buffer
+	summary := WriteDiff(&buf, plan, false)
+
+	want := `~ 1
+    ~ env: dev -> prod (rule: prod)
+    - legacy (rule: cleanup)
+    + owner = team-a (rule: cleanup)
+    ! conflict on tag env: rule "dev" sets "dev", rule "prod" sets "prod" (last-wins: "prod" wins)
+
+1 resource(s) to change: 1 to add, 1 to change, 1 to remove
+1 conflict(s) between rules
+`
+	if buf.String() != want {
+		t.Errorf("WriteDiff() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if summary != (DiffSummary{Resources: 1, Added: 1, Changed: 1, Removed: 1, Conflicts: 1}) {
+		t.Errorf("WriteDiff() summary = %+v", summary)
+	}
+}
+
+func TestWriteDiff_conflictsOnly(t *testing.T) {
+	plan := Plan{Resources: []PlannedResource{}, Conflicts: []PlannedResource{{
+		ID:      "3",
+		Changes: []TagChange{},
+		Conflicts: []TagConflict{
+			{Key: "owner", Rules: [2]string{"default", "storage"}, Values: [2]*string{String("team-a"), nil}, Winner: "default", Policy: "first-wins"},
+		},
+	}}}
+
+	var buf bytes.Buffer
+	summary := WriteDiff(&buf, plan, false)
+
+	want := `! 3
+    ! conflict on tag owner: rule "default" sets "team-a", rule "storage" removes it (first-wins: "default" wins)
+
+No tags will change
+1 conflict(s) between rules
+`
+	if buf.String() != want {
+		t.Errorf("WriteDiff() =\n%s\nwant\n%s", buf.String(), want)
+	}
+	if summary != (DiffSummary{Conflicts: 1}) {
+		t.Errorf("WriteDiff() summary = %+v", summary)
+	}
+}
@@ -0,0 +1,369 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/fake"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+)
+
+// newFakeCloud returns a subscription with two resource groups and three resources
+func newFakeCloud() (*fake.Cloud, map[string]string) {
+	cloud := fake.New("sub")
+	cloud.AddGroup("rg-a", "westeurope", map[string]string{"owner": "team-a", "env": "prod", "template": "{{ .Name }}"})
+	cloud.AddGroup("rg-b", "northeurope", nil)
+	ids := map[string]string{
+		"vm":   cloud.AddResource("rg-a", "Microsoft.Compute/virtualMachines", "vm", "westeurope", map[string]string{"test": "test"}),
+		"sa":   cloud.AddResource("rg-a", "Microsoft.Storage/storageAccounts", "sa", "westeurope", map[string]string{"test2": "test2", "test3": "test3"}),
+		"vnet": cloud.AddResource("rg-b", "Microsoft.Network/virtualNetworks", "vnet", "northeurope", map[string]string{"test2": "test2"}),
+	}
+	return cloud, ids
+}
+
+func newFakeScanner(cloud *fake.Cloud) *ResourceGroupScanner {
+	return &ResourceGroupScanner{
+		Session:         &session.AzureSession{SubscriptionID: cloud.SubscriptionID},
+		ResourcesClient: cloud.ResourcesClient(),
+		GroupsClient:    cloud.GroupsClient(),
+		Workers:         2,
+	}
+}
+
+func newFakeTagger(cloud *fake.Cloud, ruleDef rules.TagRules) *Tagger {
+	tagger := NewTagger(ruleDef, &session.AzureSession{SubscriptionID: cloud.SubscriptionID})
+	tagger.TagsClient = cloud.TagsClient()
+	tagger.SetWriteRate(0)
+	return tagger
+}
+
+func mustRules(t *testing.T, def string) rules.TagRules {
+	t.Helper()
+	ruleDef, err := rules.NewFromString(def)
+	if err != nil {
+		t.Fatalf("NewFromString() error = %v", err)
+	}
+	return ruleDef
+}
+
+func TestRewrite_fake(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  map[string]map[string]string // tags by resource after the rewrite
+	}{
+		{
+			name: "addTag",
+			rules: `
+rules:
+- name: add
+  conditions:
+  - {type: tagEqual, tag: test, value: test}
+  - {type: tagExists, tag: test}
+  actions:
+  - {type: addTag, tag: test2, value: test2}
+`,
+			want: map[string]map[string]string{"vm": {"test": "test", "test2": "test2"}},
+		},
+		{
+			name: "delTag",
+			rules: `
+rules:
+- name: delete
+  conditions:
+  - {type: tagEqual, tag: test2, value: test2}
+  actions:
+  - {type: delTag, tag: test3}
+`,
+			want: map[string]map[string]string{"sa": {"test2": "test2"}, "vnet": {"test2": "test2"}},
+		},
+		{
+			name: "cleanTags",
+			rules: `
+rules:
+- name: clean
+  conditions:
+  - {type: tagEqual, tag: test2, value: test2}
+  actions:
+  - {type: cleanTags}
+`,
+			want: map[string]map[string]string{"sa": {}, "vnet": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud, ids := newFakeCloud()
+			before := make(map[string]map[string]string)
+			for name, id := range ids {
+				before[name] = cloud.Tags(id)
+			}
+
+			res, err := newFakeScanner(cloud).GetResources()
+			if err != nil {
+				t.Fatalf("GetResources() error = %v", err)
+			}
+			if len(res) != 3 {
+				t.Fatalf("GetResources() returned %d resources, want 3", len(res))
+			}
+
+			tagger := newFakeTagger(cloud, mustRules(t, tt.rules))
+			tagger.EvaluateRules(res)
+			backupFile := NewBackupFromMatched(tagger.Matched, t.TempDir())
+
+			result, err := tagger.ExecuteActions()
+			if err != nil || result.Err() != nil {
+				t.Fatalf("ExecuteActions() error = %v, %v", err, result.Err())
+			}
+
+			for name, id := range ids {
+				want, ok := tt.want[name]
+				if !ok {
+					want = before[name]
+				}
+				if got := cloud.Tags(id); !reflect.DeepEqual(got, want) {
+					t.Errorf("tags of %s = %v, want %v", name, got, want)
+				}
+			}
+			if got := cloud.Calls("GetAtScope"); got != len(tt.want) {
+				t.Errorf("GetAtScope called %d times, want once per matched resource", got)
+			}
+			writes := 0
+			for name, want := range tt.want {
+				if !reflect.DeepEqual(want, before[name]) {
+					writes++
+				}
+			}
+			if got := cloud.Calls("UpdateAtScope") + cloud.Calls("DeleteAtScope"); got != writes {
+				t.Errorf("tags written %d times, want %d, once per changed resource", got, writes)
+			}
+
+			restorer := NewRestorerFromFile(backupFile, &session.AzureSession{})
+			restorer.TagsClient = cloud.TagsClient()
+			if err := restorer.Restore(); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+			for name, id := range ids {
+				if got := cloud.Tags(id); !reflect.DeepEqual(got, before[name]) {
+					t.Errorf("restored tags of %s = %v, want %v", name, got, before[name])
+				}
+			}
+		})
+	}
+}
+
+func TestRetagResourceGroup_fake(t *testing.T) {
+	cloud, ids := newFakeCloud()
+	scanner := newFakeScanner(cloud)
+
+	rgTags, err := scanner.GetResourceGroupTags("rg-a")
+	if err != nil {
+		t.Fatalf("GetResourceGroupTags() error = %v", err)
+	}
+	res, err := scanner.GetResourcesByResourceGroup("rg-a")
+	if err != nil {
+		t.Fatalf("GetResourcesByResourceGroup() error = %v", err)
+	}
+
+	actions := []rules.ActionItem{{"type": "cleanTags"}}
+	for k, v := range rgTags {
+		actions = append(actions, rules.ActionItem{"type": "addTag", "tag": rules.Literal(k), "value": rules.Literal(*v)})
+	}
+	tagger := newFakeTagger(cloud, rules.TagRules{Rules: []rules.Rule{{
+		Name:       "retag",
+		Conditions: []rules.Condition{{ConditionItem: rules.ConditionItem{"type": "rgEqual", "resourceGroup": "rg-a"}}},
+		Actions:    actions,
+	}}})
+	tagger.EvaluateRules(res)
+
+	if _, err := tagger.ExecuteActions(); err != nil {
+		t.Fatalf("ExecuteActions() error = %v", err)
+	}
+	want := map[string]string{"owner": "team-a", "env": "prod", "template": "{{ .Name }}"}
+	for _, name := range []string{"vm", "sa"} {
+		if got := cloud.Tags(ids[name]); !reflect.DeepEqual(got, want) {
+			t.Errorf("tags of %s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestExecuteActions_fakeFailures(t *testing.T) {
+	cloud, ids := newFakeCloud()
+	cloud.Fail(ids["vm"], fake.ResponseError(http.StatusConflict, "ScopeLocked"))
+	cloud.Fail(ids["vnet"], fake.ResponseError(http.StatusMethodNotAllowed, "TagsNotSupported"))
+
+	res, err := newFakeScanner(cloud).GetResources()
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+	tagger := newFakeTagger(cloud, mustRules(t, `
+rules:
+- name: owner
+  conditions:
+  - {type: tagNotExists, tag: owner}
+  actions:
+  - {type: addTag, tag: owner, value: team-a}
+`))
+	tagger.ContinueOnError()
+	tagger.EvaluateRules(res)
+
+	result, err := tagger.ExecuteActions()
+	if err != nil {
+		t.Fatalf("ExecuteActions() error = %v", err)
+	}
+	if len(result.Succeeded) != 1 || result.Succeeded[0].ResourceID != ids["sa"] {
+		t.Errorf("Succeeded = %+v, want only sa", result.Succeeded)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].ResourceID != ids["vm"] {
+		t.Errorf("Failed = %+v, want only vm", result.Failed)
+	}
+	if len(result.Unsupported) != 1 || result.Unsupported[0].ResourceID != ids["vnet"] {
+		t.Errorf("Unsupported = %+v, want only vnet", result.Unsupported)
+	}
+	if got := cloud.Tags(ids["sa"])["owner"]; got != "team-a" {
+		t.Errorf("owner of sa = %q, want team-a", got)
+	}
+}
+
+func TestGetResources_fakePartial(t *testing.T) {
+	cloud, _ := newFakeCloud()
+	cloud.Fail("/subscriptions/sub/resourceGroups/rg-b", fake.ResponseError(http.StatusForbidden, "AuthorizationFailed"))
+
+	res, err := newFakeScanner(cloud).GetResources()
+	var partial *PartialScanError
+	if !errors.As(err, &partial) {
+		t.Fatalf("GetResources() error = %v, want *PartialScanError", err)
+	}
+	if _, ok := partial.Failed["rg-b"]; !ok || len(partial.Failed) != 1 {
+		t.Errorf("failed groups = %v, want rg-b", partial.Failed)
+	}
+	if len(res) != 2 {
+		t.Errorf("GetResources() returned %d resources, want the 2 of rg-a", len(res))
+	}
+}
+
+func TestApplyPlan_fakeStale(t *testing.T) {
+	cloud, ids := newFakeCloud()
+	res, _ := newFakeScanner(cloud).GetResources()
+
+	tagger := newFakeTagger(cloud, mustRules(t, `
+rules:
+- name: env
+  conditions:
+  - {type: rgEqual, resourceGroup: rg-a}
+  actions:
+  - {type: addTag, tag: env, value: prod}
+`))
+	tagger.EvaluateRules(res)
+	plan, err := tagger.Plan()
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	if err := updateTagsAtScope(context.Background(), cloud.TagsClient(), ids["vm"], map[string]*string{"test": String("test")}, map[string]*string{"test": String("changed")}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := 0
+	backup := func() error {
+		backups++
+		return nil
+	}
+	if _, err := tagger.ApplyPlan(plan, backup); err == nil {
+		t.Fatal("ApplyPlan() succeeded on a stale plan")
+	}
+	if backups != 0 {
+		t.Errorf("ApplyPlan() made a backup of a stale plan")
+	}
+	if got := cloud.Tags(ids["sa"]); got["env"] != "" {
+		t.Errorf("ApplyPlan() wrote tags of sa on a stale plan: %v", got)
+	}
+
+	if err := updateTagsAtScope(context.Background(), cloud.TagsClient(), ids["vm"], map[string]*string{"test": String("changed")}, map[string]*string{"test": String("test")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	result, err := tagger.ApplyPlan(plan, backup)
+	if err != nil || result.Err() != nil {
+		t.Fatalf("ApplyPlan() error = %v, %v", err, result.Err())
+	}
+	if backups != 1 {
+		t.Errorf("ApplyPlan() made %d backup(s), want 1", backups)
+	}
+	for _, name := range []string{"vm", "sa"} {
+		if got := cloud.Tags(ids[name])["env"]; got != "prod" {
+			t.Errorf("env of %s = %q, want prod", name, got)
+		}
+	}
+}
+
+func TestExecuteActions_fakePriority(t *testing.T) {
+	cloud, ids := newFakeCloud()
+	res, err := newFakeScanner(cloud).GetResources()
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+	tagger := newFakeTagger(cloud, mustRules(t, `
+rules:
+- name: default owner
+  actions:
+  - {type: addTag, tag: owner, value: nobody}
+- name: storage owner
+  priority: 10
+  conditions:
+  - {type: typeEqual, resourceType: Microsoft.Storage/storageAccounts}
+  actions:
+  - {type: addTag, tag: owner, value: storage}
+- name: vm is final
+  priority: 20
+  stop: true
+  conditions:
+  - {type: typeEqual, resourceType: Microsoft.Compute/virtualMachines}
+  actions:
+  - {type: addTag, tag: owner, value: compute}
+- name: same priority as stop
+  priority: 20
+  actions:
+  - {type: addTag, tag: checked, value: "yes"}
+- name: below stop
+  priority: 15
+  actions:
+  - {type: addTag, tag: reviewed, value: "yes"}
+`))
+	tagger.EvaluateRules(res)
+
+	var names []string
+	for _, rule := range tagger.Matched[ids["sa"]].TagRules {
+		names = append(names, rule.Name)
+	}
+	if want := []string{"default owner", "storage owner", "below stop", "same priority as stop"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("rules of sa = %v, want %v", names, want)
+	}
+
+	result, err := tagger.ExecuteActions()
+	if err != nil {
+		t.Fatalf("ExecuteActions() error = %v", err)
+	}
+	want := map[string]map[string]string{
+		"vm":   {"test": "test", "owner": "compute", "checked": "yes"},
+		"sa":   {"test2": "test2", "test3": "test3", "owner": "storage", "checked": "yes", "reviewed": "yes"},
+		"vnet": {"test2": "test2", "owner": "nobody", "checked": "yes", "reviewed": "yes"},
+	}
+	for name, tags := range want {
+		if got := cloud.Tags(ids[name]); !reflect.DeepEqual(got, tags) {
+			t.Errorf("tags of %s = %v, want %v", name, got, tags)
+		}
+	}
+
+	var succeeded []string
+	for _, r := range result.Succeeded {
+		succeeded = append(succeeded, r.ResourceID)
+	}
+	if !sort.StringsAreSorted(succeeded) || len(succeeded) != 3 {
+		t.Errorf("Succeeded = %v, want all resources sorted by ID", succeeded)
+	}
+}
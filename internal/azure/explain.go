@@ -0,0 +1,306 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/pkg/errors"
+)
+
+// Explanation shows how the rules of a tagger were evaluated on a resource
+type Explanation struct {
+	Resource  Resource
+	Rules     []RuleOutcome
+	Changes   []TagChange   // tag changes of the actions of all matched rules
+	Conflicts []TagConflict // conflicts between the matched rules, resolved by their conflict policy
+	Err       error         // error of the actions, if they failed
+}
+
+// RuleOutcome is the outcome of a rule on a resource
+type RuleOutcome struct {
+	Name       string
+	Priority   int
+	Stop       bool
+	Matched    bool
+	Skipped    bool   // the rule does not apply, as a matched rule with a higher priority stopped the evaluation
+	StoppedBy  string // name of the rule which stopped the evaluation
+	Conditions []ConditionOutcome
+	Actions    []rules.ActionItem // actions of a matched rule, with templates expanded
+}
+
+// ConditionOutcome is the outcome of a condition on a resource. Groups have the outcomes of their conditions as children.
+type ConditionOutcome struct {
+	Type       string            // type of the condition, or anyOf, allOf and not for groups
+	Parameters map[string]string // parameters of the condition without the type, with templates expanded
+	Templates  map[string]string // parameters written as templates, before they were expanded
+	Result     bool
+	Err        error // why the condition could not be evaluated
+	Children   []ConditionOutcome
+}
+
+// Explain evaluates every rule and every condition on resource in the order of their priority, without stopping
+// at the first false condition or at a rule with stop, and applies the actions of the matched rules in memory
+func (t *Tagger) Explain(resource Resource) Explanation {
+	e := Explanation{Resource: resource}
+	data := resource
+	data.Tags = copyTags(resource.Tags)
+
+	ordered := t.Rules.ByPriority()
+	var stop ruleStop
+	for _, rule := range ordered {
+		outcome := RuleOutcome{Name: rule.Name, Priority: rule.Priority, Stop: rule.Stop, Matched: true}
+		for _, cond := range rule.Conditions {
+			c := t.explainCondition(&resource, cond)
+			outcome.Conditions = append(outcome.Conditions, c)
+			outcome.Matched = outcome.Matched && c.Result
+		}
+		if by := stop.skips(rule); by != nil {
+			outcome.Skipped, outcome.StoppedBy = true, by.Name
+			outcome.Matched = false
+		} else if outcome.Matched {
+			stop.matched(rule)
+		}
+		e.Rules = append(e.Rules, outcome)
+	}
+
+	// actions are applied like ExecuteActions does, lowest priority first, so templates see the tags set by earlier actions
+	apply := make([]int, 0, len(ordered))
+	for i := range ordered {
+		if e.Rules[i].Matched {
+			apply = append(apply, i)
+		}
+	}
+	sort.SliceStable(apply, func(a, b int) bool {
+		return ordered[apply[a]].Priority < ordered[apply[b]].Priority
+	})
+
+	run := t.newActionRun(&data)
+	for n, i := range apply {
+		rule := ordered[i]
+		if e.Err != nil {
+			break
+		}
+		for _, action := range rule.Actions {
+			expanded, err := run.apply(n, rule, action)
+			if expanded != nil {
+				e.Rules[i].Actions = append(e.Rules[i].Actions, expanded)
+			}
+			if err != nil {
+				e.Err = errors.Wrapf(err, "rule %q", rule.Name)
+				break
+			}
+		}
+	}
+
+	e.Conflicts = run.conflicts
+	e.Changes = diffTags(resource.Tags, data.Tags)
+	origins := run.origins()
+	for i := range e.Changes {
+		e.Changes[i].Rule = origins[e.Changes[i].Key]
+	}
+	return e
+}
+
+// explainCondition evaluates condition c on resource data like Eval, keeping the outcome of every condition of a group
+func (t *Tagger) explainCondition(data *Resource, c rules.Condition) ConditionOutcome {
+	switch {
+	case len(c.AnyOf) > 0:
+		o := ConditionOutcome{Type: "anyOf"}
+		for _, cond := range c.AnyOf {
+			child := t.explainCondition(data, cond)
+			o.Children = append(o.Children, child)
+			o.Result = o.Result || child.Result
+		}
+		return o
+	case len(c.AllOf) > 0:
+		o := ConditionOutcome{Type: "allOf", Result: true}
+		for _, cond := range c.AllOf {
+			child := t.explainCondition(data, cond)
+			o.Children = append(o.Children, child)
+			o.Result = o.Result && child.Result
+		}
+		return o
+	case c.Not != nil:
+		child := t.explainCondition(data, *c.Not)
+		return ConditionOutcome{Type: "not", Result: !child.Result, Children: []ConditionOutcome{child}}
+	}
+
+	o := ConditionOutcome{Type: c.GetType(), Parameters: parameters(c.ConditionItem)}
+	item := c.ConditionItem
+	if item.HasTemplates() {
+		o.Templates = make(map[string]string)
+		for k, v := range o.Parameters {
+			if strings.Contains(v, "{{") {
+				o.Templates[k] = v
+			}
+		}
+		expanded, err := item.Expand(t.templateData(data))
+		if err != nil {
+			o.Err = err
+			return o
+		}
+		item = expanded
+		o.Parameters = parameters(item)
+	}
+
+	cond, ok := t.condMap[o.Type]
+	if !ok {
+		o.Err = errors.Errorf("unknown condition type %q", o.Type)
+		return o
+	}
+	o.Result = cond(item, data)
+	return o
+}
+
+// parameters returns a copy of the parameters of a condition or action without its type
+func parameters(p map[string]string) map[string]string {
+	params := make(map[string]string, len(p))
+	for k, v := range p {
+		if k != "type" {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// formatParameters formats parameters as key="value" pairs sorted by key
+func formatParameters(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, params[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// WriteExplanation writes the resource of e, the outcome of every rule and condition, the actions of the matched
+// rules and the resulting tag changes to w. If color is true, ANSI colors are used.
+func WriteExplanation(w io.Writer, e Explanation, color bool) {
+	paint := painter(color)
+	outcome := func(result bool) string {
+		if result {
+			return paint(colorGreen, "true ")
+		}
+		return paint(colorRed, "false")
+	}
+
+	r := e.Resource
+	fmt.Fprintf(w, "Resource %s\n", r.ID)
+	var details []string
+	for _, d := range [][2]string{
+		{"name", tagValue(r.Name)},
+		{"resource group", tagValue(r.ResourceGroup)},
+		{"region", r.Region},
+		{"type", tagValue(r.Type)},
+		{"kind", tagValue(r.Kind)},
+		{"subscription", r.SubscriptionID},
+	} {
+		if d[1] != "" {
+			details = append(details, d[0]+": "+d[1])
+		}
+	}
+	fmt.Fprintf(w, "    %s\n", strings.Join(details, ", "))
+	tags := make(map[string]string, len(r.Tags))
+	for k, v := range r.Tags {
+		tags[k] = tagValue(v)
+	}
+	if len(tags) == 0 {
+		fmt.Fprintln(w, "    no tags")
+	} else {
+		fmt.Fprintf(w, "    tags: %s\n", formatParameters(tags))
+	}
+
+	var writeCondition func(c ConditionOutcome, indent string)
+	writeCondition = func(c ConditionOutcome, indent string) {
+		line := fmt.Sprintf("%s%s %s", indent, outcome(c.Result), c.Type)
+		if len(c.Parameters) > 0 {
+			line += " " + formatParameters(c.Parameters)
+		}
+		if len(c.Templates) > 0 {
+			line += " " + paint(colorFaint, "(from "+formatParameters(c.Templates)+")")
+		}
+		if c.Err != nil {
+			line += " " + paint(colorRed, "error: "+c.Err.Error())
+		}
+		fmt.Fprintln(w, line)
+		for _, child := range c.Children {
+			writeCondition(child, indent+"    ")
+		}
+	}
+
+	for _, rule := range e.Rules {
+		status := paint(colorRed, "not matched")
+		switch {
+		case rule.Skipped:
+			status = paint(colorYellow, fmt.Sprintf("skipped, rule %q stopped the evaluation", rule.StoppedBy))
+		case rule.Matched:
+			status = paint(colorGreen, "matched")
+		}
+		var attrs []string
+		if rule.Priority != 0 {
+			attrs = append(attrs, fmt.Sprintf("priority %d", rule.Priority))
+		}
+		if rule.Stop {
+			attrs = append(attrs, "stop")
+		}
+		name := fmt.Sprintf("%q", rule.Name)
+		if len(attrs) > 0 {
+			name += " (" + strings.Join(attrs, ", ") + ")"
+		}
+		fmt.Fprintf(w, "\nRule %s %s\n", name, status)
+		if len(rule.Conditions) == 0 {
+			fmt.Fprintln(w, "    no conditions, matches every resource")
+		}
+		for _, c := range rule.Conditions {
+			writeCondition(c, "    ")
+		}
+		for _, action := range rule.Actions {
+			fmt.Fprintf(w, "    action %s %s\n", action.GetType(), formatParameters(parameters(action)))
+		}
+	}
+
+	fmt.Fprintln(w)
+	if e.Err != nil {
+		fmt.Fprintf(w, "%s\n", paint(colorRed, "Actions failed: "+e.Err.Error()))
+	}
+	if len(e.Conflicts) > 0 {
+		fmt.Fprintln(w, "Conflicts:")
+		for _, c := range e.Conflicts {
+			fmt.Fprintf(w, "    %s\n", conflictLine(c, paint))
+		}
+	}
+	if len(e.Changes) == 0 {
+		fmt.Fprintln(w, "No tags will change")
+		return
+	}
+	fmt.Fprintln(w, "Tag changes:")
+	for _, change := range e.Changes {
+		fmt.Fprintf(w, "    %s\n", changeLine(change, paint))
+	}
+}
+
+// FindResource returns resource id scanned by s. Only the resource group of the resource is scanned.
+func FindResource(s Scanner, id string) (Resource, error) {
+	details, err := ParseResourceID(id)
+	if err != nil {
+		return Resource{}, err
+	}
+	resources, err := s.GetResourcesByResourceGroup(details.resourceGroup)
+	if err != nil {
+		return Resource{}, errors.Wrapf(err, "can't scan resource group %s", details.resourceGroup)
+	}
+	for _, r := range resources {
+		if strings.EqualFold(r.ID, id) {
+			return r, nil
+		}
+	}
+	return Resource{}, errors.Errorf("resource %s not found", id)
+}
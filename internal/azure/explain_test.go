@@ -0,0 +1,156 @@
+package azure
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+const explainedRules = `
+vars:
+  region: eastus
+rules:
+- name: owner
+  conditions:
+  - type: tagNotExists
+    tag: owner
+  - anyOf:
+    - type: regionEqual
+      region: "{{ .Vars.region }}"
+    - not:
+        type: rgMatches
+        glob: "*-dev"
+  actions:
+  - type: addTag
+    tag: owner
+    value: "team-{{ .Tags.env }}"
+- name: dev
+  conditions:
+  - type: rgMatches
+    glob: "*-dev"
+  - type: tagExists
+    tag: env
+  actions:
+  - type: addTag
+    tag: env
+    value: dev
+`
+
+func TestTagger_Explain(t *testing.T) {
+	ruleDef, err := rules.NewFromString(explainedRules)
+	if err != nil {
+		t.Fatalf("NewFromString() error = %v", err)
+	}
+	tagger := &Tagger{Rules: ruleDef}
+	tagger.InitCondMap()
+	tagger.InitActionMap()
+
+	e := tagger.Explain(conditionResource)
+	if len(e.Rules) != 2 || !e.Rules[0].Matched || e.Rules[1].Matched {
+		t.Fatalf("Explain() rules = %+v, want only owner matched", e.Rules)
+	}
+
+	// every condition is evaluated, even after the first false one
+	dev := e.Rules[1].Conditions
+	if len(dev) != 2 || dev[0].Result || !dev[1].Result {
+		t.Errorf("Explain() conditions of dev = %+v", dev)
+	}
+
+	anyOf := e.Rules[0].Conditions[1]
+	if anyOf.Type != "anyOf" || !anyOf.Result || len(anyOf.Children) != 2 {
+		t.Fatalf("Explain() anyOf = %+v", anyOf)
+	}
+	region := anyOf.Children[0]
+	if region.Result || region.Parameters["region"] != "eastus" || region.Templates["region"] != "{{ .Vars.region }}" {
+		t.Errorf("Explain() regionEqual = %+v", region)
+	}
+
+	actions := e.Rules[0].Actions
+	if len(actions) != 1 || actions[0]["value"] != "team-prod" {
+		t.Errorf("Explain() actions = %v", actions)
+	}
+	if len(e.Changes) != 1 || e.Changes[0].Key != "owner" || e.Changes[0].Rule != "owner" {
+		t.Errorf("Explain() changes = %+v", e.Changes)
+	}
+	if tagValue(conditionResource.Tags["owner"]) != "" {
+		t.Error("Explain() changed the tags of the resource")
+	}
+
+	var out bytes.Buffer
+	WriteExplanation(&out, e, false)
+	for _, want := range []string{
+		`Rule "owner" matched`,
+		`        false regionEqual region="eastus" (from region="{{ .Vars.region }}")`,
+		`            false rgMatches glob="*-dev"`,
+		`    action addTag tag="owner" value="team-prod"`,
+		`Rule "dev" not matched`,
+		`    + owner = team-prod (rule: owner)`,
+	} {
+		if !strings.Contains(out.String(), want+"\n") {
+			t.Errorf("WriteExplanation() output misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestFindResource(t *testing.T) {
+	scanner, err := NewSnapshotScanner("testdata/snapshot.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "/subscriptions/1/resourceGroups/rg-a/providers/Microsoft.Storage/storageAccounts/sa"
+	r, err := FindResource(scanner, strings.ToUpper(id))
+	if err != nil || r.ID != id {
+		t.Errorf("FindResource() = %v, %v, want %s", r.ID, err, id)
+	}
+	if _, err := FindResource(scanner, "/subscriptions/1/resourceGroups/rg-a/providers/Microsoft.Storage/storageAccounts/missing"); err == nil {
+		t.Error("FindResource() expected an error for a missing resource")
+	}
+	if _, err := FindResource(scanner, "not-an-id"); err == nil {
+		t.Error("FindResource() expected an error for an invalid ID")
+	}
+}
+
+func TestTagger_ExplainStop(t *testing.T) {
+	tagger := &Tagger{Rules: mustRules(t, `
+rules:
+- name: default
+  actions:
+  - {type: addTag, tag: owner, value: nobody}
+- name: final
+  priority: 5
+  stop: true
+  conditions:
+  - {type: tagExists, tag: env}
+  actions:
+  - {type: addTag, tag: owner, value: team}
+- name: peer
+  priority: 5
+  actions:
+  - {type: addTag, tag: reviewed, value: "yes"}
+`)}
+	tagger.InitCondMap()
+	tagger.InitActionMap()
+
+	e := tagger.Explain(conditionResource)
+	if e.Rules[0].Name != "final" || !e.Rules[0].Matched {
+		t.Fatalf("Explain() first rule = %+v, want final matched", e.Rules[0])
+	}
+	if e.Rules[1].Name != "peer" || !e.Rules[1].Matched || e.Rules[1].Skipped {
+		t.Errorf("Explain() rule peer = %+v, want matched, as it has the priority of final", e.Rules[1])
+	}
+	if !e.Rules[2].Skipped || e.Rules[2].Matched || e.Rules[2].StoppedBy != "final" {
+		t.Errorf("Explain() rule default = %+v, want skipped by final", e.Rules[2])
+	}
+	if len(e.Changes) != 2 || tagValue(e.Changes[0].NewValue) != "team" || tagValue(e.Changes[1].NewValue) != "yes" {
+		t.Errorf("Explain() changes = %+v", e.Changes)
+	}
+
+	var out bytes.Buffer
+	WriteExplanation(&out, e, false)
+	if !strings.Contains(out.String(), `Rule "final" (priority 5, stop) matched`) ||
+		!strings.Contains(out.String(), `Rule "default" skipped, rule "final" stopped the evaluation`) {
+		t.Errorf("WriteExplanation() output:\n%s", out.String())
+	}
+}
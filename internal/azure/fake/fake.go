@@ -0,0 +1,424 @@
+// Package fake implements in-memory fakes of the ARM clients used by the tag manager, so that scans and tag writes
+// can be tested without Azure.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// DefaultPageSize is the number of items in a page of the fake list pagers
+const DefaultPageSize = 2
+
+// Cloud is an in-memory subscription with resource groups and resources. It is safe for concurrent use.
+type Cloud struct {
+	SubscriptionID string
+	PageSize       int // number of items in a page returned by pagers
+
+	mu        sync.Mutex
+	groups    []*armresources.ResourceGroup
+	resources map[string][]*armresources.GenericResourceExpanded // resources by lowercased name of their resource group
+	failures  map[string]error                                   // errors returned for operations on a scope
+	calls     map[string]int                                     // number of calls of each operation
+}
+
+// New creates an empty Cloud with subscription
+func New(subscription string) *Cloud {
+	return &Cloud{
+		SubscriptionID: subscription,
+		PageSize:       DefaultPageSize,
+		resources:      make(map[string][]*armresources.GenericResourceExpanded),
+		failures:       make(map[string]error),
+		calls:          make(map[string]int),
+	}
+}
+
+// AddGroup adds a resource group with tags and returns its ID
+func (c *Cloud) AddGroup(name, location string, tags map[string]string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", c.SubscriptionID, name)
+	c.groups = append(c.groups, &armresources.ResourceGroup{
+		ID:       &id,
+		Name:     &name,
+		Location: &location,
+		Tags:     toTags(tags),
+	})
+	return id
+}
+
+// AddResource adds a resource of resourceType (for example Microsoft.Storage/storageAccounts) with tags to the
+// resource group rg and returns its ID
+func (c *Cloud) AddResource(rg, resourceType, name, location string, tags map[string]string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", c.SubscriptionID, rg, resourceType, name)
+	key := strings.ToLower(rg)
+	c.resources[key] = append(c.resources[key], &armresources.GenericResourceExpanded{
+		ID:       &id,
+		Name:     &name,
+		Type:     &resourceType,
+		Location: &location,
+		Tags:     toTags(tags),
+	})
+	return id
+}
+
+// Fail makes every following operation on scope fail with err. The scope is either a resource ID, for failing
+// its tag operations, or a resource group ID, for failing its resource list and Get. A nil err removes the failure.
+func (c *Cloud) Fail(scope string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		delete(c.failures, strings.ToLower(scope))
+		return
+	}
+	c.failures[strings.ToLower(scope)] = err
+}
+
+// Tags returns a copy of the tags of resource or resource group id, nil if there is no such scope
+func (c *Cloud) Tags(id string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tags, ok := c.tags(id)
+	if !ok {
+		return nil
+	}
+	tab := make(map[string]string, len(*tags))
+	for k, v := range *tags {
+		if v != nil {
+			tab[k] = *v
+		} else {
+			tab[k] = ""
+		}
+	}
+	return tab
+}
+
+// Resource returns a copy of the resource id, nil if there is no such resource
+func (c *Cloud) Resource(id string) *armresources.GenericResourceExpanded {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, list := range c.resources {
+		for _, r := range list {
+			if strings.EqualFold(*r.ID, id) {
+				return copyResources([]*armresources.GenericResourceExpanded{r})[0]
+			}
+		}
+	}
+	return nil
+}
+
+// Calls returns the number of calls of operation, for example "UpdateAtScope"
+func (c *Cloud) Calls(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[operation]
+}
+
+// TagsClient returns a fake of armresources.TagsClient
+func (c *Cloud) TagsClient() *TagsClient {
+	return &TagsClient{cloud: c}
+}
+
+// ResourcesClient returns a fake of armresources.Client
+func (c *Cloud) ResourcesClient() *ResourcesClient {
+	return &ResourcesClient{cloud: c}
+}
+
+// GroupsClient returns a fake of armresources.ResourceGroupsClient
+func (c *Cloud) GroupsClient() *GroupsClient {
+	return &GroupsClient{cloud: c}
+}
+
+// call records a call of operation on scope and returns the failure set for scope. Must be called with c.mu held.
+func (c *Cloud) call(operation, scope string) error {
+	c.calls[operation]++
+	return c.failures[strings.ToLower(scope)]
+}
+
+// tags returns a pointer to the tags of scope id. Must be called with c.mu held.
+func (c *Cloud) tags(id string) (*map[string]*string, bool) {
+	for _, g := range c.groups {
+		if strings.EqualFold(*g.ID, id) {
+			return &g.Tags, true
+		}
+	}
+	for _, list := range c.resources {
+		for _, r := range list {
+			if strings.EqualFold(*r.ID, id) {
+				return &r.Tags, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// group returns the resource group named name, nil if there is none. Must be called with c.mu held.
+func (c *Cloud) group(name string) *armresources.ResourceGroup {
+	for _, g := range c.groups {
+		if strings.EqualFold(*g.Name, name) {
+			return g
+		}
+	}
+	return nil
+}
+
+// TagsClient is an in-memory fake of armresources.TagsClient
+type TagsClient struct {
+	cloud *Cloud
+}
+
+// GetAtScope returns the tags of scope
+func (t *TagsClient) GetAtScope(ctx context.Context, scope string, options *armresources.TagsClientGetAtScopeOptions) (armresources.TagsClientGetAtScopeResponse, error) {
+	c := t.cloud
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("GetAtScope", scope); err != nil {
+		return armresources.TagsClientGetAtScopeResponse{}, err
+	}
+	tags, ok := c.tags(scope)
+	if !ok {
+		return armresources.TagsClientGetAtScopeResponse{}, ResponseError(http.StatusNotFound, "ResourceNotFound")
+	}
+	return armresources.TagsClientGetAtScopeResponse{
+		TagsResource: armresources.TagsResource{Properties: &armresources.Tags{Tags: copyTags(*tags)}},
+	}, nil
+}
+
+// UpdateAtScope merges, replaces or deletes tags of scope, like the tags API does
+func (t *TagsClient) UpdateAtScope(ctx context.Context, scope string, parameters armresources.TagsPatchResource, options *armresources.TagsClientUpdateAtScopeOptions) (armresources.TagsClientUpdateAtScopeResponse, error) {
+	c := t.cloud
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("UpdateAtScope", scope); err != nil {
+		return armresources.TagsClientUpdateAtScopeResponse{}, err
+	}
+	tags, ok := c.tags(scope)
+	if !ok {
+		return armresources.TagsClientUpdateAtScopeResponse{}, ResponseError(http.StatusNotFound, "ResourceNotFound")
+	}
+	if parameters.Operation == nil || parameters.Properties == nil {
+		return armresources.TagsClientUpdateAtScopeResponse{}, ResponseError(http.StatusBadRequest, "InvalidRequestContent")
+	}
+
+	patch := parameters.Properties.Tags
+	switch *parameters.Operation {
+	case armresources.TagsPatchOperationReplace:
+		*tags = copyTags(patch)
+	case armresources.TagsPatchOperationMerge:
+		if *tags == nil {
+			*tags = make(map[string]*string)
+		}
+		for k, v := range patch {
+			(*tags)[k] = copyValue(v)
+		}
+	case armresources.TagsPatchOperationDelete:
+		for k, v := range patch {
+			if cur, ok := (*tags)[k]; ok && (v == nil || *v == "" || (cur != nil && *cur == *v)) {
+				delete(*tags, k)
+			}
+		}
+	default:
+		return armresources.TagsClientUpdateAtScopeResponse{}, ResponseError(http.StatusBadRequest, "InvalidTagsPatchOperation")
+	}
+
+	return armresources.TagsClientUpdateAtScopeResponse{
+		TagsResource: armresources.TagsResource{Properties: &armresources.Tags{Tags: copyTags(*tags)}},
+	}, nil
+}
+
+// DeleteAtScope deletes all tags of scope
+func (t *TagsClient) DeleteAtScope(ctx context.Context, scope string, options *armresources.TagsClientDeleteAtScopeOptions) (armresources.TagsClientDeleteAtScopeResponse, error) {
+	c := t.cloud
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("DeleteAtScope", scope); err != nil {
+		return armresources.TagsClientDeleteAtScopeResponse{}, err
+	}
+	tags, ok := c.tags(scope)
+	if !ok {
+		return armresources.TagsClientDeleteAtScopeResponse{}, ResponseError(http.StatusNotFound, "ResourceNotFound")
+	}
+	*tags = nil
+	return armresources.TagsClientDeleteAtScopeResponse{}, nil
+}
+
+// ResourcesClient is an in-memory fake of armresources.Client
+type ResourcesClient struct {
+	cloud *Cloud
+}
+
+// NewListByResourceGroupPager returns a pager of resources in resource group rg
+func (r *ResourcesClient) NewListByResourceGroupPager(rg string, options *armresources.ClientListByResourceGroupOptions) *runtime.Pager[armresources.ClientListByResourceGroupResponse] {
+	c := r.cloud
+	groupID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", c.SubscriptionID, rg)
+
+	return newPager(c, "ListByResourceGroup", groupID, func() ([]*armresources.GenericResourceExpanded, error) {
+		if c.group(rg) == nil {
+			return nil, ResponseError(http.StatusNotFound, "ResourceGroupNotFound")
+		}
+		return c.resources[strings.ToLower(rg)], nil
+	}, func(page []*armresources.GenericResourceExpanded, next *string) armresources.ClientListByResourceGroupResponse {
+		return armresources.ClientListByResourceGroupResponse{
+			ResourceListResult: armresources.ResourceListResult{Value: copyResources(page), NextLink: next},
+		}
+	})
+}
+
+// GroupsClient is an in-memory fake of armresources.ResourceGroupsClient
+type GroupsClient struct {
+	cloud *Cloud
+}
+
+// NewListPager returns a pager of all resource groups
+func (g *GroupsClient) NewListPager(options *armresources.ResourceGroupsClientListOptions) *runtime.Pager[armresources.ResourceGroupsClientListResponse] {
+	c := g.cloud
+	scope := "/subscriptions/" + c.SubscriptionID
+
+	return newPager(c, "ListGroups", scope, func() ([]*armresources.ResourceGroup, error) {
+		return c.groups, nil
+	}, func(page []*armresources.ResourceGroup, next *string) armresources.ResourceGroupsClientListResponse {
+		return armresources.ResourceGroupsClientListResponse{
+			ResourceGroupListResult: armresources.ResourceGroupListResult{Value: copyGroups(page), NextLink: next},
+		}
+	})
+}
+
+// Get returns resource group rg
+func (g *GroupsClient) Get(ctx context.Context, rg string, options *armresources.ResourceGroupsClientGetOptions) (armresources.ResourceGroupsClientGetResponse, error) {
+	c := g.cloud
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.call("GetGroup", fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", c.SubscriptionID, rg)); err != nil {
+		return armresources.ResourceGroupsClientGetResponse{}, err
+	}
+	group := c.group(rg)
+	if group == nil {
+		return armresources.ResourceGroupsClientGetResponse{}, ResponseError(http.StatusNotFound, "ResourceGroupNotFound")
+	}
+	return armresources.ResourceGroupsClientGetResponse{ResourceGroup: *copyGroups([]*armresources.ResourceGroup{group})[0]}, nil
+}
+
+// newPager returns a pager over the items returned by list, in pages of c.PageSize items made by page. Every page is
+// a call of operation on scope, so that a failure of scope fails the page.
+func newPager[T, R any](c *Cloud, operation, scope string, list func() ([]T, error), page func([]T, *string) R) *runtime.Pager[R] {
+	offset := 0
+	return runtime.NewPager(runtime.PagingHandler[R]{
+		More: func(resp R) bool {
+			return offset > 0
+		},
+		Fetcher: func(ctx context.Context, _ *R) (R, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			var empty R
+			if err := c.call(operation, scope); err != nil {
+				return empty, err
+			}
+			items, err := list()
+			if err != nil {
+				return empty, err
+			}
+
+			size := c.PageSize
+			if size <= 0 {
+				size = len(items)
+			}
+			end := offset + size
+			if end >= len(items) {
+				end = len(items)
+			}
+
+			var next *string
+			start := offset
+			if end < len(items) {
+				link := fmt.Sprintf("%s?page=%d", scope, end)
+				next, offset = &link, end
+			} else {
+				offset = 0
+			}
+			return page(items[start:end], next), nil
+		},
+	})
+}
+
+// ResponseError returns the error the SDK returns for an ARM response with status and error code
+func ResponseError(status int, code string) error {
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"X-Ms-Error-Code": []string{code}},
+		Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"error": {"code": %q}}`, code))),
+		Request:    httptest.NewRequest(http.MethodGet, "https://management.azure.com/", nil),
+	}
+	return runtime.NewResponseError(resp)
+}
+
+func toTags(tags map[string]string) map[string]*string {
+	if tags == nil {
+		return nil
+	}
+	tab := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		v := v
+		tab[k] = &v
+	}
+	return tab
+}
+
+func copyValue(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	s := *v
+	return &s
+}
+
+func copyTags(tags map[string]*string) map[string]*string {
+	if tags == nil {
+		return nil
+	}
+	tab := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		tab[k] = copyValue(v)
+	}
+	return tab
+}
+
+func copyResources(list []*armresources.GenericResourceExpanded) []*armresources.GenericResourceExpanded {
+	tab := make([]*armresources.GenericResourceExpanded, 0, len(list))
+	for _, r := range list {
+		cp := *r
+		cp.Tags = copyTags(r.Tags)
+		tab = append(tab, &cp)
+	}
+	return tab
+}
+
+func copyGroups(list []*armresources.ResourceGroup) []*armresources.ResourceGroup {
+	tab := make([]*armresources.ResourceGroup, 0, len(list))
+	for _, g := range list {
+		cp := *g
+		cp.Tags = copyTags(g.Tags)
+		tab = append(tab, &cp)
+	}
+	return tab
+}
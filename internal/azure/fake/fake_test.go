@@ -0,0 +1,82 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/pkg/errors"
+)
+
+func TestTagsClient_UpdateAtScope(t *testing.T) {
+	str := func(v string) *string { return &v }
+
+	tests := []struct {
+		name      string
+		operation armresources.TagsPatchOperation
+		patch     map[string]*string
+		want      map[string]string
+	}{
+		{name: "merge", operation: armresources.TagsPatchOperationMerge, patch: map[string]*string{"env": str("prod"), "owner": str("a")},
+			want: map[string]string{"env": "prod", "owner": "a", "legacy": "yes"}},
+		{name: "replace", operation: armresources.TagsPatchOperationReplace, patch: map[string]*string{"owner": str("a")},
+			want: map[string]string{"owner": "a"}},
+		{name: "delete", operation: armresources.TagsPatchOperationDelete, patch: map[string]*string{"legacy": str("yes"), "env": str("prod")},
+			want: map[string]string{"env": "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := New("sub")
+			cloud.AddGroup("rg", "westeurope", nil)
+			id := cloud.AddResource("rg", "Microsoft.Storage/storageAccounts", "sa", "westeurope", map[string]string{"env": "dev", "legacy": "yes"})
+
+			op := tt.operation
+			_, err := cloud.TagsClient().UpdateAtScope(context.Background(), id, armresources.TagsPatchResource{
+				Operation:  &op,
+				Properties: &armresources.Tags{Tags: tt.patch},
+			}, nil)
+			if err != nil {
+				t.Fatalf("UpdateAtScope() error = %v", err)
+			}
+			if got := cloud.Tags(id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourcesClient_pages(t *testing.T) {
+	cloud := New("sub")
+	groupID := cloud.AddGroup("rg", "westeurope", nil)
+	for _, name := range []string{"a", "b", "c"} {
+		cloud.AddResource("rg", "Microsoft.Storage/storageAccounts", name, "westeurope", nil)
+	}
+
+	pager := cloud.ResourcesClient().NewListByResourceGroupPager("rg", nil)
+	var names []string
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			t.Fatalf("NextPage() error = %v", err)
+		}
+		for _, r := range page.Value {
+			names = append(names, *r.Name)
+		}
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+	if got := cloud.Calls("ListByResourceGroup"); got != 2 {
+		t.Errorf("Calls() = %d, want 2 pages", got)
+	}
+
+	cloud.Fail(groupID, ResponseError(http.StatusForbidden, "AuthorizationFailed"))
+	_, err := cloud.ResourcesClient().NewListByResourceGroupPager("rg", nil).NextPage(context.Background())
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.ErrorCode != "AuthorizationFailed" {
+		t.Errorf("NextPage() error = %v, want AuthorizationFailed", err)
+	}
+}
@@ -0,0 +1,173 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/armtest"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/fake"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+)
+
+// newTestServer starts an ARM server with groups resource groups of three resources each
+func newTestServer(t *testing.T, groups int) (*armtest.Server, []string) {
+	t.Helper()
+	cloud := fake.New("sub")
+	var ids []string
+	for g := 0; g < groups; g++ {
+		rg := fmt.Sprintf("rg-%d", g)
+		cloud.AddGroup(rg, "westeurope", nil)
+		for r := 0; r < 3; r++ {
+			ids = append(ids, cloud.AddResource(rg, "Microsoft.Storage/storageAccounts", fmt.Sprintf("sa%d%d", g, r), "westeurope", map[string]string{"env": "dev"}))
+		}
+	}
+
+	server := armtest.NewServer(cloud)
+	t.Cleanup(server.Close)
+	return server, ids
+}
+
+// withRetry sets the retry policy for the duration of the test
+func withRetry(t *testing.T, policy RetryPolicy) {
+	saved := Retry
+	Retry = policy
+	t.Cleanup(func() { Retry = saved })
+}
+
+func countRequests(server *armtest.Server, prefix string) int {
+	n := 0
+	for _, r := range server.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func newServerTagger(t *testing.T, sess *session.AzureSession) *Tagger {
+	tagger := NewTagger(mustRules(t, `
+rules:
+- name: prod
+  conditions:
+  - {type: tagEqual, tag: env, value: dev}
+  actions:
+  - {type: addTag, tag: env, value: prod}
+`), sess)
+	tagger.SetWorkers(4)
+	tagger.SetWriteRate(0)
+	return tagger
+}
+
+func TestIntegration_rewrite(t *testing.T) {
+	server, ids := newTestServer(t, 3)
+	withRetry(t, RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	server.Throttle(http.MethodPatch, "/providers/Microsoft.Resources/tags/default", 4, time.Millisecond)
+	server.Throttle(http.MethodGet, "/resourcegroups/rg-1/resources", 1, time.Millisecond)
+
+	sess := server.Session()
+	scanner := NewResourceGroupScanner(sess)
+	res, err := scanner.GetResources()
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+	if len(res) != len(ids) {
+		t.Fatalf("GetResources() returned %d resources, want %d", len(res), len(ids))
+	}
+	if pages := countRequests(server, "GET /subscriptions/sub/resourceGroups/rg-0/resources"); pages != 2 {
+		t.Errorf("resources of rg-0 listed in %d pages, want 2", pages)
+	}
+
+	tagger := newServerTagger(t, sess)
+	tagger.EvaluateRules(res)
+	result, err := tagger.ExecuteActions()
+	if err != nil || result.Err() != nil {
+		t.Fatalf("ExecuteActions() error = %v, %v", err, result.Err())
+	}
+	if len(result.Succeeded) != len(ids) {
+		t.Errorf("Succeeded %d resources, want %d", len(result.Succeeded), len(ids))
+	}
+	for _, id := range ids {
+		if got := server.Cloud.Tags(id)["env"]; got != "prod" {
+			t.Errorf("env of %s = %q, want prod", id, got)
+		}
+	}
+	if writes := countRequests(server, "PATCH "); writes != len(ids)+4 {
+		t.Errorf("%d tag writes, want %d and 4 throttled", writes, len(ids))
+	}
+}
+
+func TestIntegration_throttledTooLong(t *testing.T) {
+	server, ids := newTestServer(t, 1)
+	withRetry(t, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	server.Throttle(http.MethodPatch, ids[0], 10, time.Millisecond)
+
+	sess := server.Session()
+	res, err := NewResourceGroupScanner(sess).GetResources()
+	if err != nil {
+		t.Fatalf("GetResources() error = %v", err)
+	}
+
+	tagger := newServerTagger(t, sess)
+	tagger.ContinueOnError()
+	tagger.EvaluateRules(res)
+	result, err := tagger.ExecuteActions()
+	if err != nil {
+		t.Fatalf("ExecuteActions() error = %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].ResourceID != ids[0] {
+		t.Fatalf("Failed = %+v, want only %s", result.Failed, ids[0])
+	}
+	if !strings.Contains(result.Failed[0].Err.Error(), "429") {
+		t.Errorf("Failed error = %v, want the throttling error", result.Failed[0].Err)
+	}
+	if got := countRequests(server, "PATCH "+ids[0]); got != 3 {
+		t.Errorf("throttled write sent %d times, want 3", got)
+	}
+	if len(result.Succeeded) != len(ids)-1 {
+		t.Errorf("Succeeded %d resources, want %d", len(result.Succeeded), len(ids)-1)
+	}
+}
+
+func TestIntegration_retriedWritesAreLimited(t *testing.T) {
+	server, ids := newTestServer(t, 1)
+	withRetry(t, RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	server.Throttle(http.MethodPatch, ids[0], 2, time.Millisecond)
+
+	tagger := newServerTagger(t, server.Session())
+	waits := 0
+	limit := tagger.writeLimit(ids[0])
+	wait := func(ctx context.Context) error {
+		waits++
+		return limit(ctx)
+	}
+	err := updateTagsAtScope(context.Background(), tagger.TagsClient, ids[0],
+		map[string]*string{"env": String("dev")}, map[string]*string{"env": String("prod")}, wait)
+	if err != nil {
+		t.Fatalf("updateTagsAtScope() error = %v", err)
+	}
+	if writes := countRequests(server, "PATCH "+ids[0]); writes != 3 || waits != writes {
+		t.Errorf("%d writes waited %d times for the write limiter, want 3 each", writes, waits)
+	}
+}
+
+func TestIntegration_partialScan(t *testing.T) {
+	server, _ := newTestServer(t, 3)
+	server.Cloud.Fail("/subscriptions/sub/resourceGroups/rg-2", fake.ResponseError(http.StatusForbidden, "AuthorizationFailed"))
+
+	res, err := NewResourceGroupScanner(server.Session()).GetResources()
+	var partial *PartialScanError
+	if !errors.As(err, &partial) {
+		t.Fatalf("GetResources() error = %v, want *PartialScanError", err)
+	}
+	if len(partial.Failed) != 1 || !strings.Contains(partial.Failed["rg-2"].Error(), "AuthorizationFailed") {
+		t.Errorf("failed groups = %v, want rg-2 with AuthorizationFailed", partial.Failed)
+	}
+	if len(res) != 6 {
+		t.Errorf("GetResources() returned %d resources, want the 6 of rg-0 and rg-1", len(res))
+	}
+}
@@ -0,0 +1,180 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Plan represents tag changes computed from matched rules. It can be saved to a file, reviewed and applied later
+type Plan struct {
+	CreatedAt time.Time         `json:"createdAt"`
+	Resources []PlannedResource `json:"resources"`
+	Conflicts []PlannedResource `json:"conflicts,omitempty"` // resources whose tags do not change, with conflicts between their rules
+}
+
+// PlannedResource represents planned tag changes of a single resource
+type PlannedResource struct {
+	ID        string             `json:"id"`
+	Rules     []string           `json:"rules"`
+	Current   map[string]*string `json:"current"`
+	Desired   map[string]*string `json:"desired"`
+	Changes   []TagChange        `json:"changes"`
+	Conflicts []TagConflict      `json:"conflicts,omitempty"` // conflicts between the rules, resolved by their conflict policy
+}
+
+// Plan computes the tag changes of all matched resources without executing any action.
+// Resources whose tags would not change are left out of the plan, unless their rules conflict: they are kept
+// in Conflicts. With the error conflict policy, a conflict fails the plan.
+func (t *Tagger) Plan() (Plan, error) {
+	plan := Plan{CreatedAt: time.Now().UTC(), Resources: make([]PlannedResource, 0)}
+
+	for _, id := range t.MatchedIDs() {
+		matched := t.Matched[id]
+		resource := matched.Resource
+		resource.ID = id
+		resource.Tags = copyTags(matched.Resource.Tags)
+		origins, conflicts, err := t.applyActions(&resource, matched.TagRules)
+		if err != nil {
+			return Plan{}, errors.Wrapf(err, "Plan(): can't plan actions on [%s]", id)
+		}
+
+		changes := diffTags(matched.Resource.Tags, resource.Tags)
+		if len(changes) == 0 && len(conflicts) == 0 {
+			continue
+		}
+		for i := range changes {
+			changes[i].Rule = origins[changes[i].Key]
+		}
+
+		names := make([]string, 0, len(matched.TagRules))
+		for _, rule := range matched.TagRules {
+			names = append(names, rule.Name)
+		}
+
+		planned := PlannedResource{
+			ID:        id,
+			Rules:     names,
+			Current:   copyTags(matched.Resource.Tags),
+			Desired:   resource.Tags,
+			Changes:   changes,
+			Conflicts: conflicts,
+		}
+		if len(changes) == 0 {
+			plan.Conflicts = append(plan.Conflicts, planned)
+		} else {
+			plan.Resources = append(plan.Resources, planned)
+		}
+	}
+	return plan, nil
+}
+
+// ApplyPlan writes the desired tags of every resource in plan and returns the outcome for every resource.
+// Nothing is written if the tags of any resource changed since the plan was made, as the plan would not be valid anymore.
+// If backup is not nil, it is called once the plan is found valid and before any tag is written; if it fails, nothing
+// is written. Execution stops on the first failed resource, unless ContinueOnError was set.
+func (t *Tagger) ApplyPlan(plan Plan, backup func() error) (ExecutionResult, error) {
+	var (
+		result   ExecutionResult
+		firstErr error
+		mu       sync.Mutex
+		current  = make([]map[string]*string, len(plan.Resources))
+		stale    = make([]string, 0)
+	)
+
+	runPool(t.workers, len(plan.Resources), func(i int) bool {
+		planned := plan.Resources[i]
+		tags, err := getTagsAtScope(context.Background(), t.TagsClient, planned.ID)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "ApplyPlan(): can't get tags of [%s]", planned.ID)
+			}
+			return false
+		}
+		if !equalTags(tags, planned.Current) {
+			stale = append(stale, planned.ID)
+		}
+		current[i] = tags
+		return true
+	})
+
+	if firstErr != nil {
+		return result, firstErr
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return result, errors.Errorf("ApplyPlan(): tags of %d resource(s) changed since the plan was made, create a new plan: %s", len(stale), strings.Join(stale, ", "))
+	}
+	if backup != nil {
+		if err := backup(); err != nil {
+			return result, errors.Wrap(err, "ApplyPlan(): backup failed")
+		}
+	}
+
+	runPool(t.workers, len(plan.Resources), func(i int) bool {
+		planned := plan.Resources[i]
+		r := ResourceResult{ResourceID: planned.ID}
+		for _, name := range planned.Rules {
+			r.Executions = append(r.Executions, ActionExecution{ResourceID: planned.ID, RuleName: name})
+		}
+
+		if !t.dryRun {
+			log.Infof("Applying plan on [%s]", planned.ID)
+			r.Err = updateTagsAtScope(context.Background(), t.TagsClient, planned.ID, current[i], planned.Desired, t.writeLimit(planned.ID))
+			if r.Err != nil {
+				r.Err = errors.Wrapf(r.Err, "can't update tags of [%s]", planned.ID)
+			} else {
+				r.Changes = planned.Changes
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.add(r)
+		if r.Err != nil && !t.keepGoing && !isUnsupported(r.Err) {
+			if firstErr == nil {
+				firstErr = errors.Wrap(r.Err, "ApplyPlan()")
+			}
+			return false
+		}
+		return true
+	})
+	result.sort()
+	return result, firstErr
+}
+
+// WriteFile saves the plan as json to filename
+func (p Plan) WriteFile(filename string) error {
+	dat, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "can't marshal plan")
+	}
+	if err := ioutil.WriteFile(filename, dat, 0644); err != nil {
+		return errors.Wrapf(err, "can't write plan to %s", filename)
+	}
+	return nil
+}
+
+// NewPlanFromFile reads a plan saved in filename
+func NewPlanFromFile(filename string) (Plan, error) {
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return Plan{}, errors.Wrap(err, "error opening the plan file")
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(dat, &plan); err != nil {
+		return Plan{}, errors.Wrapf(err, "can't unmarshal plan from %s", filename)
+	}
+	return plan, nil
+}
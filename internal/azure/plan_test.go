@@ -0,0 +1,83 @@
+package azure
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+func TestTagger_Plan(t *testing.T) {
+	tagger := &Tagger{Rules: rules.TagRules{Conflicts: rules.ConflictFirstWins}, Matched: map[string]Matched{
+		"3": {
+			Resource: Resource{ID: "3", Tags: map[string]*string{"owner": String("team-a")}},
+			TagRules: []rules.Rule{
+				{Name: "default", Actions: []rules.ActionItem{{"type": "addTag", "tag": "owner", "value": "team-a"}}},
+				{Name: "storage", Actions: []rules.ActionItem{{"type": "addTag", "tag": "owner", "value": "team-storage"}}},
+			},
+		},
+		"2": {
+			Resource: Resource{ID: "2", Tags: map[string]*string{"env": String("dev"), "legacy": String("yes")}},
+			TagRules: []rules.Rule{
+				{Name: "prod", Actions: []rules.ActionItem{{"type": "addTag", "tag": "env", "value": "prod"}}},
+				{Name: "cleanup", Actions: []rules.ActionItem{
+					{"type": "delTag", "tag": "legacy"},
+					{"type": "addTag", "tag": "owner", "value": "team-a"},
+				}},
+			},
+		},
+		"1": {
+			Resource: Resource{ID: "1", Tags: map[string]*string{"env": String("prod")}},
+			TagRules: []rules.Rule{
+				{Name: "prod", Actions: []rules.ActionItem{{"type": "addTag", "tag": "env", "value": "prod"}}},
+			},
+		},
+	}}
+	tagger.InitActionMap()
+
+	plan, err := tagger.Plan()
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	want := []PlannedResource{{
+		ID:      "2",
+		Rules:   []string{"prod", "cleanup"},
+		Current: map[string]*string{"env": String("dev"), "legacy": String("yes")},
+		Desired: map[string]*string{"env": String("prod"), "owner": String("team-a")},
+		Changes: []TagChange{
+			{Key: "env", Action: TagChanged, OldValue: String("dev"), NewValue: String("prod"), Rule: "prod"},
+			{Key: "legacy", Action: TagRemoved, OldValue: String("yes"), Rule: "cleanup"},
+			{Key: "owner", Action: TagAdded, NewValue: String("team-a"), Rule: "cleanup"},
+		},
+	}}
+	if !reflect.DeepEqual(plan.Resources, want) {
+		t.Errorf("Plan() = %+v, want %+v", plan.Resources, want)
+	}
+
+	wantConflicts := []PlannedResource{{
+		ID:      "3",
+		Rules:   []string{"default", "storage"},
+		Current: map[string]*string{"owner": String("team-a")},
+		Desired: map[string]*string{"owner": String("team-a")},
+		Changes: []TagChange{},
+		Conflicts: []TagConflict{{Key: "owner", Rules: [2]string{"default", "storage"},
+			Values: [2]*string{String("team-a"), String("team-storage")}, Winner: "default", Policy: rules.ConflictFirstWins}},
+	}}
+	if !reflect.DeepEqual(plan.Conflicts, wantConflicts) {
+		t.Errorf("Plan() conflicts = %+v, want %+v", plan.Conflicts, wantConflicts)
+	}
+
+	filename := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.WriteFile(filename); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	read, err := NewPlanFromFile(filename)
+	if err != nil {
+		t.Fatalf("NewPlanFromFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(read.Resources, plan.Resources) || !reflect.DeepEqual(read.Conflicts, plan.Conflicts) || !read.CreatedAt.Equal(plan.CreatedAt) {
+		t.Errorf("NewPlanFromFile() = %+v, want %+v", read, plan)
+	}
+}
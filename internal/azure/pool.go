@@ -0,0 +1,93 @@
+package azure
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultWorkers is the default number of concurrent scans and tag writes
+	DefaultWorkers = 8
+	// DefaultWriteRate is the default number of tag writes per second. ARM refills
+	// the write token bucket of a subscription with 10 writes per second.
+	DefaultWriteRate = 10
+)
+
+// runPool calls fn for every index in [0, n) on at most workers goroutines.
+// Once any call of fn returns false, no new calls are started.
+func runPool(workers, n int, fn func(i int) bool) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var (
+		wg      sync.WaitGroup
+		stopped int32
+		jobs    = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if atomic.LoadInt32(&stopped) == 1 {
+					continue
+				}
+				if !fn(i) {
+					atomic.StoreInt32(&stopped, 1)
+				}
+			}
+		}()
+	}
+	for i := 0; i < n && atomic.LoadInt32(&stopped) == 0; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// newWriteLimiter returns a token bucket limiter allowing perSecond writes with bursts of the same size.
+// A rate of zero or less disables limiting.
+func newWriteLimiter(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	burst := int(perSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
+
+// waitWrite blocks until the write limiter of the subscription of resource id allows the next write.
+// ARM limits writes per subscription, so every subscription has its own token bucket.
+func (t *Tagger) waitWrite(ctx context.Context, id string) error {
+	t.limitersMu.Lock()
+	if t.limiters == nil {
+		t.limiters = make(map[string]*rate.Limiter)
+	}
+	sub := strings.ToLower(SubscriptionOf(id))
+	limiter, ok := t.limiters[sub]
+	if !ok {
+		limiter = newWriteLimiter(t.writeRate)
+		t.limiters[sub] = limiter
+	}
+	t.limitersMu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+// writeLimit returns a function waiting for the write limiter of the subscription of resource id. It is passed to
+// updateTagsAtScope, so every attempt of a write, retries included, is limited.
+func (t *Tagger) writeLimit(id string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return t.waitWrite(ctx, id)
+	}
+}
@@ -0,0 +1,50 @@
+package azure
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPool(t *testing.T) {
+	var (
+		running, peak int32
+		mu            sync.Mutex
+		seen          = make(map[int]bool)
+	)
+	runPool(3, 20, func(i int) bool {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		seen[i] = true
+		return true
+	})
+
+	if len(seen) != 20 {
+		t.Errorf("runPool() called fn for %d indexes, want 20", len(seen))
+	}
+	if peak > 3 {
+		t.Errorf("runPool() ran %d calls concurrently, want at most 3", peak)
+	}
+}
+
+func TestRunPool_Stop(t *testing.T) {
+	var calls int32
+	runPool(1, 10, func(i int) bool {
+		atomic.AddInt32(&calls, 1)
+		return i < 2
+	})
+	if calls != 3 {
+		t.Errorf("runPool() made %d calls, want 3", calls)
+	}
+}
@@ -0,0 +1,230 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// graphPageSize is the number of rows requested from Resource Graph in one page, which is the maximum it allows
+const graphPageSize = 1000
+
+const (
+	graphResourceProjection = "project id, name, type, kind, location, resourceGroup, subscriptionId, tags"
+	graphGroupsTable        = "ResourceContainers | where type =~ 'microsoft.resources/subscriptions/resourcegroups'"
+)
+
+// ResourceGraphScanner scans resources with Azure Resource Graph queries, which return the resources of all
+// resource groups and subscriptions at once. Resource Graph is eventually consistent, so tags changed just
+// before the scan may not be seen yet.
+type ResourceGraphScanner struct {
+	Session          *session.AzureSession
+	Client           *armresourcegraph.Client
+	Subscriptions    []string // subscriptions to query, all subscriptions of the tenant when empty
+	ManagementGroups []string // management groups to query instead of subscriptions
+	Filter           string   // KQL filter applied to the resources before rules are evaluated
+}
+
+// graphResource is a row of the resource query
+type graphResource struct {
+	ID             string             `json:"id"`
+	Name           string             `json:"name"`
+	Type           string             `json:"type"`
+	Kind           string             `json:"kind"`
+	Location       string             `json:"location"`
+	ResourceGroup  string             `json:"resourceGroup"`
+	SubscriptionID string             `json:"subscriptionId"`
+	Tags           map[string]*string `json:"tags"`
+}
+
+// NewResourceGraphScanner creates ResourceGraphScanner with Azure session s, querying the subscription of the session
+func NewResourceGraphScanner(s *session.AzureSession) *ResourceGraphScanner {
+	client, _ := armresourcegraph.NewClient(s.Credential, s.ClientOptions())
+
+	scanner := &ResourceGraphScanner{
+		Session: s,
+		Client:  client,
+	}
+	if s.SubscriptionID != "" {
+		scanner.Subscriptions = []string{s.SubscriptionID}
+	}
+	return scanner
+}
+
+// GetResources returns all resources matching the filter of the scanner
+func (r ResourceGraphScanner) GetResources() ([]Resource, error) {
+	rows, err := r.queryResources("Resources", r.Filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetResources()")
+	}
+	return rows, nil
+}
+
+// GetResourcesByResourceGroup returns resources matching the filter of the scanner in a resource group rg
+func (r ResourceGraphScanner) GetResourcesByResourceGroup(rg string) ([]Resource, error) {
+	rows, err := r.queryResources("Resources", "resourceGroup =~ "+kqlString(rg), r.Filter)
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetResourcesByResourceGroup(rg=%s)", rg)
+	}
+	return rows, nil
+}
+
+// GetGroups returns names of resource groups
+func (r ResourceGraphScanner) GetGroups() ([]string, error) {
+	rows, err := r.query(graphQuery(graphGroupsTable, "project id, name"))
+	if err != nil {
+		return nil, errors.Wrap(err, "GetGroups()")
+	}
+
+	tab := make([]string, 0, len(rows))
+	for _, row := range rows {
+		tab = append(tab, row.Name)
+	}
+	return tab, nil
+}
+
+// GetResourceGroupTags returns a map of key value tags of a resource group rg
+func (r ResourceGraphScanner) GetResourceGroupTags(rg string) (map[string]*string, error) {
+	rows, err := r.query(graphQuery(graphGroupsTable, "project id, name, tags", "name =~ "+kqlString(rg)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "GetResourceGroupTags(rg=%s)", rg)
+	}
+	if len(rows) == 0 {
+		return nil, errors.Errorf("GetResourceGroupTags(rg=%s): resource group not found", rg)
+	}
+	return rows[0].Tags, nil
+}
+
+// queryResources returns resources of table matching all filters
+func (r ResourceGraphScanner) queryResources(table string, filters ...string) ([]Resource, error) {
+	rows, err := r.query(graphQuery(table, graphResourceProjection, filters...))
+	if err != nil {
+		return nil, err
+	}
+
+	tab := make([]Resource, 0, len(rows))
+	for _, row := range rows {
+		tab = append(tab, row.resource())
+		log.Info(row.Name, " ", row.ID, " tags: ", row.Tags)
+	}
+	return tab, nil
+}
+
+// query returns the rows of query q from all its pages
+func (r ResourceGraphScanner) query(q string) ([]graphResource, error) {
+	log.Infof("Resource Graph query: %s", q)
+
+	request := armresourcegraph.QueryRequest{
+		Query:            &q,
+		Subscriptions:    toPtrs(r.Subscriptions),
+		ManagementGroups: toPtrs(r.ManagementGroups),
+		Options: &armresourcegraph.QueryRequestOptions{
+			ResultFormat: toPtr(armresourcegraph.ResultFormatObjectArray),
+			Top:          toPtr(int32(graphPageSize)),
+		},
+	}
+
+	tab := make([]graphResource, 0)
+	for {
+		var resp armresourcegraph.ClientResourcesResponse
+		err := Retry.Do(context.Background(), "ResourceGraph", func(ctx context.Context) error {
+			var err error
+			resp, err = r.Client.Resources(ctx, request, nil)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Resources() failed")
+		}
+
+		rows, err := decodeGraphRows(resp.Data)
+		if err != nil {
+			return nil, err
+		}
+		tab = append(tab, rows...)
+
+		if resp.SkipToken == nil || *resp.SkipToken == "" {
+			if resp.ResultTruncated != nil && *resp.ResultTruncated == armresourcegraph.ResultTruncatedTrue {
+				return nil, errors.New("query result is truncated")
+			}
+			return tab, nil
+		}
+		request.Options.SkipToken = resp.SkipToken
+	}
+}
+
+// decodeGraphRows decodes data of a query response in the object array format
+func decodeGraphRows(data interface{}) ([]graphResource, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read query result")
+	}
+	var rows []graphResource
+	if err := json.Unmarshal(b, &rows); err != nil {
+		return nil, errors.Wrap(err, "can't read query result")
+	}
+	return rows, nil
+}
+
+// resource converts the row to Resource. Resource Graph returns names of resource groups in lowercase, so
+// the resource group is taken from the ID when possible.
+func (g graphResource) resource() Resource {
+	rg := g.ResourceGroup
+	if details, err := ParseResourceID(g.ID); err == nil {
+		rg = details.resourceGroup
+	}
+
+	res := Resource{
+		Platform:       "azure",
+		SubscriptionID: g.SubscriptionID,
+		ID:             g.ID,
+		Name:           String(g.Name),
+		Region:         g.Location,
+		Type:           String(g.Type),
+		Tags:           g.Tags,
+		ResourceGroup:  String(rg),
+	}
+	if g.Kind != "" {
+		res.Kind = String(g.Kind)
+	}
+	return res
+}
+
+// graphQuery returns a KQL query of table with all non-empty filters, projected by projection. Results are
+// ordered by id, which keeps the pages stable.
+func graphQuery(table, projection string, filters ...string) string {
+	q := table
+	for _, f := range filters {
+		if f = strings.TrimSpace(f); f != "" {
+			q += fmt.Sprintf(" | where %s", f)
+		}
+	}
+	return q + " | " + projection + " | order by id asc"
+}
+
+// kqlString quotes s as a KQL string literal
+func kqlString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// toPtr returns a pointer to v
+func toPtr[T any](v T) *T {
+	return &v
+}
+
+// toPtrs converts a slice of strings to a slice of string pointers, nil when s is empty
+func toPtrs(s []string) []*string {
+	if len(s) == 0 {
+		return nil
+	}
+	tab := make([]*string, 0, len(s))
+	for _, v := range s {
+		tab = append(tab, String(v))
+	}
+	return tab
+}
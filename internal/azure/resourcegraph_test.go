@@ -0,0 +1,56 @@
+package azure
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGraphQuery(t *testing.T) {
+	got := graphQuery("Resources", "project id, name", "resourceGroup =~ "+kqlString("rg-'a'"), "  ", "type =~ 'microsoft.compute/virtualmachines'")
+	want := `Resources | where resourceGroup =~ 'rg-\'a\'' | where type =~ 'microsoft.compute/virtualmachines' | project id, name | order by id asc`
+	if got != want {
+		t.Errorf("graphQuery() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeGraphRows(t *testing.T) {
+	var data interface{}
+	page := `[
+		{"id": "/subscriptions/1/resourceGroups/RG-Team/providers/Microsoft.Storage/storageAccounts/sa", "name": "sa",
+		 "type": "microsoft.storage/storageaccounts", "kind": "StorageV2", "location": "westeurope",
+		 "resourceGroup": "rg-team", "subscriptionId": "1", "tags": {"env": "prod"}},
+		{"id": "/subscriptions/1/resourceGroups/RG-Team/providers/Microsoft.Network/virtualNetworks/vnet", "name": "vnet",
+		 "type": "microsoft.network/virtualnetworks", "kind": "", "location": "westeurope",
+		 "resourceGroup": "rg-team", "subscriptionId": "1", "tags": null}
+	]`
+	if err := json.Unmarshal([]byte(page), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := decodeGraphRows(data)
+	if err != nil {
+		t.Fatalf("decodeGraphRows() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("decodeGraphRows() returned %d rows, want 2", len(rows))
+	}
+
+	want := Resource{
+		Platform:       "azure",
+		SubscriptionID: "1",
+		ID:             "/subscriptions/1/resourceGroups/RG-Team/providers/Microsoft.Storage/storageAccounts/sa",
+		Name:           String("sa"),
+		Region:         "westeurope",
+		Type:           String("microsoft.storage/storageaccounts"),
+		Kind:           String("StorageV2"),
+		Tags:           map[string]*string{"env": String("prod")},
+		ResourceGroup:  String("RG-Team"),
+	}
+	if got := rows[0].resource(); !reflect.DeepEqual(got, want) {
+		t.Errorf("resource() = %+v, want %+v", got, want)
+	}
+	if got := rows[1].resource(); got.Kind != nil || got.Tags != nil {
+		t.Errorf("resource() = %+v, want no kind and no tags", got)
+	}
+}
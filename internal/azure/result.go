@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/pkg/errors"
+)
+
+// ExecutionResult lists the outcome of executing actions on every matched resource
+type ExecutionResult struct {
+	Succeeded   []ResourceResult // tags were written
+	Skipped     []ResourceResult // nothing to write, tags are up to date or the run is dry
+	Failed      []ResourceResult // tags could not be read or written
+	Unsupported []ResourceResult // the resource type does not support tags
+}
+
+// ResourceResult represents the outcome of executing actions on a single resource
+type ResourceResult struct {
+	ResourceID string
+	Executions []ActionExecution // rules and their actions executed on the resource
+	Changes    []TagChange       // tag changes written to the resource
+	Err        error             // reason of a failure
+}
+
+// unsupportedErrorCodes are ARM error codes returned for resources which can't be tagged
+var unsupportedErrorCodes = []string{
+	"ResourceTypeNotSupported",
+	"InvalidResourceType",
+	"UnsupportedResourceType",
+	"TagsNotSupported",
+}
+
+// isUnsupported returns true if err means the resource does not support tags
+func isUnsupported(err error) bool {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	if respErr.StatusCode == http.StatusMethodNotAllowed {
+		return true
+	}
+	for _, code := range unsupportedErrorCodes {
+		if strings.EqualFold(respErr.ErrorCode, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound returns true if err means the resource or resource group does not exist
+func isNotFound(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound
+}
+
+// add records result r in the right list, depending on its error and changes
+func (e *ExecutionResult) add(r ResourceResult) {
+	switch {
+	case r.Err != nil && isUnsupported(r.Err):
+		e.Unsupported = append(e.Unsupported, r)
+	case r.Err != nil:
+		e.Failed = append(e.Failed, r)
+	case len(r.Changes) == 0:
+		e.Skipped = append(e.Skipped, r)
+	default:
+		e.Succeeded = append(e.Succeeded, r)
+	}
+}
+
+// sort orders the resources of every list by their ID, as they are added in the order they complete
+func (e *ExecutionResult) sort() {
+	for _, list := range [][]ResourceResult{e.Succeeded, e.Skipped, e.Failed, e.Unsupported} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].ResourceID < list[j].ResourceID
+		})
+	}
+}
+
+// Executions returns the executed rules and their actions of all resources
+func (e ExecutionResult) Executions() []ActionExecution {
+	ael := make([]ActionExecution, 0)
+	for _, list := range [][]ResourceResult{e.Succeeded, e.Skipped, e.Failed, e.Unsupported} {
+		for _, r := range list {
+			ael = append(ael, r.Executions...)
+		}
+	}
+	return ael
+}
+
+// Err returns an error describing all failed resources, or nil if none failed
+func (e ExecutionResult) Err() error {
+	if len(e.Failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(e.Failed))
+	for _, r := range e.Failed {
+		msgs = append(msgs, r.Err.Error())
+	}
+	return errors.Errorf("actions failed on %d resource(s): %s", len(e.Failed), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,56 @@
+package azure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/pkg/errors"
+)
+
+// responseError returns the error the SDK returns for an ARM response with status and error code
+func responseError(status int, code string) error {
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"error": {"code": %q}}`, code))),
+		Request:    httptest.NewRequest(http.MethodPatch, "https://management.azure.com/subscriptions/1", nil),
+	}
+	return runtime.NewResponseError(resp)
+}
+
+func TestExecutionResult_add(t *testing.T) {
+	var result ExecutionResult
+	result.add(ResourceResult{ResourceID: "written", Changes: []TagChange{{Key: "env", Action: TagAdded}}})
+	result.add(ResourceResult{ResourceID: "up to date"})
+	result.add(ResourceResult{ResourceID: "locked", Err: errors.Wrap(responseError(http.StatusConflict, "ScopeLocked"), "locked")})
+	result.add(ResourceResult{ResourceID: "extension", Err: errors.Wrap(responseError(http.StatusBadRequest, "InvalidResourceType"), "extension")})
+	result.add(ResourceResult{ResourceID: "no tags", Err: responseError(http.StatusMethodNotAllowed, "")})
+
+	got := map[string]int{
+		"succeeded":   len(result.Succeeded),
+		"skipped":     len(result.Skipped),
+		"failed":      len(result.Failed),
+		"unsupported": len(result.Unsupported),
+	}
+	want := map[string]int{"succeeded": 1, "skipped": 1, "failed": 1, "unsupported": 2}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("add() %s = %d, want %d", k, got[k], v)
+		}
+	}
+	if result.Failed[0].ResourceID != "locked" {
+		t.Errorf("add() failed = %s, want locked", result.Failed[0].ResourceID)
+	}
+	if result.Err() == nil {
+		t.Errorf("Err() = nil, want error")
+	}
+	if (ExecutionResult{Succeeded: result.Succeeded}).Err() != nil {
+		t.Errorf("Err() != nil without failures")
+	}
+}
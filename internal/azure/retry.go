@@ -0,0 +1,150 @@
+package azure
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryPolicy retries ARM calls which failed because of throttling or a transient error.
+// The delay between attempts grows exponentially with random jitter, unless ARM asks
+// for a specific delay with the Retry-After header.
+type RetryPolicy struct {
+	MaxRetries int           // number of retries after the first attempt
+	BaseDelay  time.Duration // delay before the first retry
+	MaxDelay   time.Duration // upper bound of any delay
+}
+
+// Retry is the policy used for all ARM calls
+var Retry = RetryPolicy{
+	MaxRetries: 6,
+	BaseDelay:  2 * time.Second,
+	MaxDelay:   2 * time.Minute,
+}
+
+// retryableStatusCodes are HTTP status codes of throttled and transient failures
+var retryableStatusCodes = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// Do calls fn until it succeeds, fails with an error which is not retryable or runs out of retries.
+// op names the call in logs.
+func (p RetryPolicy) Do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || !IsRetryable(err) || attempt >= p.MaxRetries {
+			return err
+		}
+
+		delay := p.delay(attempt, err)
+		log.Warnf("%s failed (attempt %d of %d), retrying in %s: %s", op, attempt+1, p.MaxRetries+1, delay, shortError(err))
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), err.Error())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// delay returns how long to wait before retrying after attempt failed with err
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	if d, ok := retryAfter(err); ok {
+		if p.MaxDelay > 0 && d > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return d
+	}
+
+	d := p.BaseDelay << uint(attempt)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	// equal jitter: at least half of the exponential delay, so retries of concurrent workers spread out
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// IsRetryable returns true if err is caused by throttling or a transient failure of ARM or the network
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return retryableStatusCodes[respErr.StatusCode]
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfter returns the delay requested by ARM in the response headers of err
+func retryAfter(err error) (time.Duration, bool) {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) || respErr.RawResponse == nil {
+		return 0, false
+	}
+	header := respErr.RawResponse.Header
+
+	for _, h := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if v := header.Get(h); v != "" {
+			if ms, err := strconv.Atoi(v); err == nil && ms >= 0 {
+				return time.Duration(ms) * time.Millisecond, true
+			}
+		}
+	}
+
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// shortError returns the status of ARM response errors instead of their multi line description
+func shortError(err error) string {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		if respErr.ErrorCode != "" {
+			return strconv.Itoa(respErr.StatusCode) + " " + respErr.ErrorCode
+		}
+		return strconv.Itoa(respErr.StatusCode) + " " + http.StatusText(respErr.StatusCode)
+	}
+	return err.Error()
+}
+
+// nextPage fetches the next page of pager with the retry policy
+func nextPage[T any](ctx context.Context, pager *runtime.Pager[T]) (T, error) {
+	var resp T
+	err := Retry.Do(ctx, "NextPage", func(ctx context.Context) error {
+		var err error
+		resp, err = pager.NextPage(ctx)
+		return err
+	})
+	return resp, err
+}
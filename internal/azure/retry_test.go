@@ -0,0 +1,68 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "throttled then success", errs: []error{responseError(http.StatusTooManyRequests, "TooManyRequests"), responseError(http.StatusServiceUnavailable, ""), nil}, wantCalls: 3},
+		{name: "fatal error", errs: []error{responseError(http.StatusForbidden, "AuthorizationFailed")}, wantCalls: 1, wantErr: true},
+		{name: "out of retries", errs: []error{
+			responseError(http.StatusInternalServerError, ""), responseError(http.StatusInternalServerError, ""),
+			responseError(http.StatusInternalServerError, ""), responseError(http.StatusInternalServerError, ""),
+		}, wantCalls: 4, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Do() made %d calls, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	throttled := responseError(http.StatusTooManyRequests, "")
+	throttled.(*azcore.ResponseError).RawResponse.Header.Set("Retry-After", "7")
+	if d := policy.delay(0, throttled); d != 7*time.Second {
+		t.Errorf("delay() with Retry-After = %s, want 7s", d)
+	}
+
+	throttled.(*azcore.ResponseError).RawResponse.Header.Set("Retry-After", "600")
+	if d := policy.delay(0, throttled); d != 10*time.Second {
+		t.Errorf("delay() with long Retry-After = %s, want 10s", d)
+	}
+
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		d := policy.delay(attempt, responseError(http.StatusServiceUnavailable, ""))
+		if d < max/2 || d > max {
+			t.Errorf("delay(%d) = %s, want between %s and %s", attempt, d, max/2, max)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loader reads rule files and the files they include. Every file is read once, even if it is
+// included by several files, so a shared base policy can be included from anywhere.
+type loader struct {
+	loaded map[string]bool   // absolute paths of files already merged
+	stack  []string          // absolute paths of files being loaded, to detect include cycles
+	names  []string          // names of files being loaded, as given by the user or joined to the including file
+	origin map[string]string // file defining each rule name
+	merged TagRules
+
+	warnings []Issue // warnings of all files
+}
+
+func newLoader() *loader {
+	return &loader{loaded: make(map[string]bool), origin: make(map[string]string)}
+}
+
+// loadFile reads filename, merges the files it includes and then its own definitions
+func (l *loader) loadFile(filename string) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return errors.Wrapf(err, "can't resolve %s", filename)
+	}
+	for i, f := range l.stack {
+		if f == path {
+			cycle := append(append([]string{}, l.names[i:]...), filename)
+			return errors.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if l.loaded[path] {
+		return nil
+	}
+
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return errors.Wrap(err, "error opening the file")
+	}
+	rulesDef, warnings, err := parseRulesDefinitions(string(dat), filename)
+	if err != nil {
+		if _, ok := err.(*ValidationError); ok {
+			return err
+		}
+		return errors.Wrapf(err, "in %s", filename)
+	}
+
+	l.stack = append(l.stack, path)
+	l.names = append(l.names, filename)
+	defer func() {
+		l.stack = l.stack[:len(l.stack)-1]
+		l.names = l.names[:len(l.names)-1]
+	}()
+
+	if err := l.include(filepath.Dir(filename), rulesDef.Include); err != nil {
+		l.warnings = append(l.warnings, warnings...)
+		return err
+	}
+	l.loaded[path] = true
+	l.warnings = append(l.warnings, warnings...)
+	return l.merge(rulesDef, filename)
+}
+
+// include loads every include pattern in order, relative to dir. Files matched by a glob are loaded
+// in lexical order; a glob may match nothing, while a plain path must exist.
+func (l *loader) include(dir string, includes []string) error {
+	for _, inc := range includes {
+		pattern := inc
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		files := []string{pattern}
+		if strings.ContainsAny(inc, "*?[") {
+			var err error
+			if files, err = filepath.Glob(pattern); err != nil {
+				return errors.Wrapf(err, "invalid include %q", inc)
+			}
+		}
+		for _, f := range files {
+			if err := l.loadFile(f); err != nil {
+				return errors.Wrapf(err, "can't include %q", inc)
+			}
+		}
+	}
+	return nil
+}
+
+// merge appends the rules and tests of rulesDef, read from filename, to the merged rules. Vars, dryrun and
+// conflicts of later files override the ones of earlier files, rule names must be unique across all files.
+func (l *loader) merge(rulesDef TagRules, filename string) error {
+	for _, rule := range rulesDef.Rules {
+		if rule.Name == "" {
+			continue
+		}
+		if first, ok := l.origin[rule.Name]; ok {
+			if first == filename {
+				return errors.Errorf("rule %q is defined twice in %s", rule.Name, filename)
+			}
+			return errors.Errorf("rule %q in %s is already defined in %s", rule.Name, filename, first)
+		}
+		l.origin[rule.Name] = filename
+	}
+
+	l.merged.Rules = append(l.merged.Rules, rulesDef.Rules...)
+	l.merged.Tests = append(l.merged.Tests, rulesDef.Tests...)
+	if rulesDef.DryRun != nil {
+		l.merged.DryRun = rulesDef.DryRun
+	}
+	if rulesDef.Conflicts != "" {
+		l.merged.Conflicts = rulesDef.Conflicts
+	}
+	for k, v := range rulesDef.Vars {
+		if l.merged.Vars == nil {
+			l.merged.Vars = make(map[string]string)
+		}
+		l.merged.Vars[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package rules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewFromFile_include(t *testing.T) {
+	got, err := NewFromFile("testdata/include/main.yaml")
+	if err != nil {
+		t.Fatalf("NewFromFile() error = %v", err)
+	}
+
+	var names []string
+	for _, rule := range got.Rules {
+		names = append(names, rule.Name)
+	}
+	if want := []string{"owner", "compute", "network", "env"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("NewFromFile() rules = %v, want %v", names, want)
+	}
+	if want := map[string]string{"company": "contoso", "env": "prod"}; !reflect.DeepEqual(got.Vars, want) {
+		t.Errorf("NewFromFile() vars = %v, want %v", got.Vars, want)
+	}
+	if got.DryRun == nil || !*got.DryRun {
+		t.Errorf("NewFromFile() dryrun = %v, want true", got.DryRun)
+	}
+}
+
+func TestNewFromFile_includeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "cycle", file: "testdata/include/cycle-a.yaml", wantErr: "include cycle: "},
+		{name: "duplicate rule", file: "testdata/include/duplicate.yaml", wantErr: `rule "owner" in testdata/include/duplicate.yaml is already defined in`},
+		{name: "missing file", file: "testdata/include/missing.yaml", wantErr: `can't include "teams/missing.yaml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFromFile(tt.file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewFromFile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFile_invalidInclude(t *testing.T) {
+	_, warnings, err := LoadFile("testdata/include/invalid.yaml")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("LoadFile() error = %v, want a validation error", err)
+	}
+	if want := `can't include "broken/rules.yaml"`; !strings.Contains(err.Error(), want) {
+		t.Errorf("LoadFile() error = %v, want %q", err, want)
+	}
+
+	var got []string
+	for _, issue := range append(verr.Issues, warnings...) {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`testdata/include/broken/rules.yaml:6:5: warning: unknown parameter "value" of condition tagExists`,
+		`testdata/include/broken/rules.yaml:8:5: unknown action type "addTg", expected one of addTag, cleanTags, delTag`,
+		`testdata/include/invalid.yaml:4:3: warning: rule "no actions" has no actions`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadFile() issues =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// compiled keeps already compiled patterns, so every pattern is compiled only once
+var compiled sync.Map
+
+// Pattern returns the compiled regular expression of the condition. The expression is taken
+// either from the parameter pattern (regular expression) or from the parameter glob, where
+// * matches any sequence of characters and ? matches a single character. Globs are case insensitive,
+// as most of Azure names are.
+func (p ConditionItem) Pattern() (*regexp.Regexp, error) {
+	return p.compilePattern(false)
+}
+
+// PatternIgnoreCase returns the compiled expression of the condition like Pattern, with regular expressions
+// made case insensitive too. It is used for values Azure does not compare by case, like resource types.
+func (p ConditionItem) PatternIgnoreCase() (*regexp.Regexp, error) {
+	return p.compilePattern(true)
+}
+
+func (p ConditionItem) compilePattern(ignoreCase bool) (*regexp.Regexp, error) {
+	pattern, hasPattern := p["pattern"]
+	glob, hasGlob := p["glob"]
+
+	var key string
+	switch {
+	case hasPattern && hasGlob:
+		return nil, errors.New("only one of pattern or glob can be given")
+	case hasPattern && ignoreCase:
+		key = "ipattern:" + pattern
+	case hasPattern:
+		key = "pattern:" + pattern
+	case hasGlob:
+		key = "glob:" + glob
+	default:
+		return nil, errors.New("pattern or glob is required")
+	}
+
+	if re, ok := compiled.Load(key); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	expr := pattern
+	switch {
+	case hasGlob:
+		expr = globToRegexp(glob)
+	case ignoreCase:
+		expr = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't compile %q", expr)
+	}
+	compiled.Store(key, re)
+	return re, nil
+}
+
+// globToRegexp converts glob into an anchored, case insensitive regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// position is a line and a column in a rules definition
+type position struct {
+	Line   int
+	Column int
+}
+
+// positions keeps where every element of a rules definition is written, by its path like rules[0].conditions[1].tag,
+// together with the keys of every object and the keys whose values are lists
+type positions struct {
+	at    map[string]position
+	keys  map[string][]string
+	lists map[string]bool
+}
+
+// indexPositions reads the positions of all elements of a YAML or JSON rules definition. Definitions which
+// can't be parsed have no positions.
+func indexPositions(data []byte) positions {
+	p := positions{at: make(map[string]position), keys: make(map[string][]string), lists: make(map[string]bool)}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return p
+	}
+	p.walk(doc.Content[0], "")
+	return p
+}
+
+func (p positions) walk(node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			child := key.Value
+			if path != "" {
+				child = path + "." + key.Value
+			}
+			p.at[child] = position{Line: key.Line, Column: key.Column}
+			p.keys[path] = append(p.keys[path], key.Value)
+			p.lists[child] = value.Kind == yaml.SequenceNode
+			p.walk(value, child)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			p.at[child] = position{Line: item.Line, Column: item.Column}
+			p.walk(item, child)
+		}
+	}
+}
+
+// find returns the position of path, or of its closest parent with a known position
+func (p positions) find(path string) (position, bool) {
+	for path != "" {
+		if pos, ok := p.at[path]; ok {
+			return pos, true
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return position{}, false
+}
@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"encoding/json"
+)
+
+type schema map[string]interface{}
+
+// JSONSchema returns the JSON Schema of rules definitions, generated from ConditionSpecs and ActionSpecs.
+// It lets editors check rule files while they are written.
+func JSONSchema() ([]byte, error) {
+	parameter := schema{"oneOf": []schema{
+		{"type": "string"},
+		{"type": "array", "items": schema{"type": "string", "pattern": "^[^,]*$"}},
+	}}
+
+	groups := []schema{
+		group("anyOf", schema{"type": "array", "minItems": 1, "items": schema{"$ref": "#/definitions/condition"}}),
+		group("allOf", schema{"type": "array", "minItems": 1, "items": schema{"$ref": "#/definitions/condition"}}),
+		group("not", schema{"$ref": "#/definitions/condition"}),
+		{"$ref": "#/definitions/conditionItem"},
+	}
+
+	s := schema{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  "rules.schema.json",
+		"title":                "Azure tag manager rules",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": schema{
+			"dryrun":    schema{"type": "boolean"},
+			"conflicts": schema{"enum": ConflictPolicies, "description": "policy resolving conflicts between rules writing the same tag, last-wins when empty. Rules are applied from the lowest priority up, so first-wins lets the lowest priority win"},
+			"include":   schema{"type": "array", "items": schema{"type": "string"}, "description": "rule files merged before the rules, relative paths or globs"},
+			"vars":      schema{"type": "object", "additionalProperties": schema{"type": "string"}, "description": "constants available to templates as {{ .Vars.name }}"},
+			"rules":     schema{"type": "array", "items": schema{"$ref": "#/definitions/rule"}},
+			"tests":     schema{"type": "array", "items": schema{"$ref": "#/definitions/test"}, "description": "tests of the rules, run by the test command"},
+		},
+		"definitions": schema{
+			"rule": schema{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": schema{
+					"name":       schema{"type": "string"},
+					"priority":   schema{"type": "integer", "description": "rules with a higher priority are evaluated first and their actions applied last"},
+					"stop":       schema{"type": "boolean", "description": "if the rule matches, rules with a lower priority are not evaluated"},
+					"conditions": schema{"type": "array", "items": schema{"$ref": "#/definitions/condition"}},
+					"actions":    schema{"type": "array", "items": schema{"$ref": "#/definitions/action"}},
+				},
+			},
+			"test": schema{
+				"type":                 "object",
+				"required":             []string{"name", "resource"},
+				"additionalProperties": false,
+				"properties": schema{
+					"name":     schema{"type": "string"},
+					"resource": schema{"$ref": "#/definitions/testResource"},
+					"matches":  schema{"type": "array", "items": schema{"type": "string"}, "description": "names of the rules expected to match, in the order they are evaluated: highest priority first, then as written"},
+					"tags":     schema{"type": "object", "additionalProperties": schema{"type": "string"}, "description": "tags expected after all actions"},
+				},
+			},
+			"testResource": schema{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": schema{
+					"id":             schema{"type": "string"},
+					"name":           schema{"type": "string"},
+					"resourceGroup":  schema{"type": "string"},
+					"region":         schema{"type": "string"},
+					"type":           schema{"type": "string"},
+					"kind":           schema{"type": "string"},
+					"subscriptionId": schema{"type": "string"},
+					"tags":           schema{"type": "object", "additionalProperties": schema{"type": "string"}},
+				},
+			},
+			"condition":     schema{"oneOf": groups},
+			"conditionItem": item(ConditionSpecs, parameter),
+			"action":        item(ActionSpecs, parameter),
+		},
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// group returns the schema of a condition group with the single key op
+func group(op string, value schema) schema {
+	return schema{
+		"type":                 "object",
+		"required":             []string{op},
+		"additionalProperties": false,
+		"properties":           schema{op: value},
+	}
+}
+
+// item returns the schema of a condition or action with a type of specs
+func item(specs map[string]Spec, parameter schema) schema {
+	var cases []schema
+	for _, typ := range specTypes(specs) {
+		spec := specs[typ]
+		then := schema{"required": append([]string{"type"}, spec.Required...)}
+		scalars := schema{}
+		for _, name := range spec.params() {
+			if !containsString(spec.Lists, name) {
+				scalars[name] = schema{"type": "string"}
+			}
+		}
+		if len(scalars) > 0 {
+			then["properties"] = scalars
+		}
+		if spec.Pattern {
+			then["oneOf"] = []schema{{"required": []string{"pattern"}}, {"required": []string{"glob"}}}
+		}
+		cases = append(cases, schema{
+			"if":   schema{"properties": schema{"type": schema{"const": typ}}},
+			"then": then,
+		})
+	}
+
+	types := make([]schema, 0, len(specs))
+	for _, typ := range specTypes(specs) {
+		types = append(types, schema{"const": typ, "description": specs[typ].Description})
+	}
+	return schema{
+		"type":                 "object",
+		"required":             []string{"type"},
+		"properties":           schema{"type": schema{"oneOf": types}},
+		"additionalProperties": parameter,
+		"allOf":                cases,
+	}
+}
@@ -0,0 +1,52 @@
+package rules
+
+// Spec describes the parameters of a condition or action type
+type Spec struct {
+	Description string
+	Required    []string // parameters which must be given, they may be empty
+	Optional    []string // parameters which may be given
+	Pattern     bool     // exactly one of the parameters pattern or glob must be given
+	Lists       []string // parameters which may be given as a list of strings instead of a comma separated string
+}
+
+// params returns all parameters of the spec, without the type
+func (s Spec) params() []string {
+	params := append(append([]string{}, s.Required...), s.Optional...)
+	if s.Pattern {
+		params = append(params, "pattern", "glob")
+	}
+	return params
+}
+
+// ConditionSpecs lists the supported condition types
+var ConditionSpecs = map[string]Spec{
+	"noTags":            {Description: "the resource has no tags"},
+	"tagEqual":          {Description: "tag has value", Required: []string{"tag", "value"}},
+	"tagNotEqual":       {Description: "tag exists with a value other than value", Required: []string{"tag", "value"}},
+	"tagExists":         {Description: "tag exists", Required: []string{"tag"}},
+	"tagNotExists":      {Description: "tag does not exist", Required: []string{"tag"}},
+	"regionEqual":       {Description: "the resource is in region", Required: []string{"region"}},
+	"regionNotEqual":    {Description: "the resource is not in region", Required: []string{"region"}},
+	"rgEqual":           {Description: "the resource is in resourceGroup", Required: []string{"resourceGroup"}},
+	"rgNotEqual":        {Description: "the resource is not in resourceGroup", Required: []string{"resourceGroup"}},
+	"resEqual":          {Description: "the resource is not in resourceGroup, as rgNotEqual", Required: []string{"resourceGroup"}},
+	"tagMatches":        {Description: "any tag key matches pattern or glob", Pattern: true},
+	"tagValueMatches":   {Description: "the value of tag matches pattern or glob", Required: []string{"tag"}, Pattern: true},
+	"nameMatches":       {Description: "the resource name matches pattern or glob", Pattern: true},
+	"rgMatches":         {Description: "the resource group matches pattern or glob", Pattern: true},
+	"typeEqual":         {Description: "the resource type is resourceType", Required: []string{"resourceType"}},
+	"typeIn":            {Description: "the resource type is one of values", Required: []string{"values"}, Lists: []string{"values"}},
+	"typeMatches":       {Description: "the resource type matches pattern or glob", Pattern: true},
+	"kindEqual":         {Description: "the resource kind is kind", Required: []string{"kind"}},
+	"subscriptionEqual": {Description: "the subscription ID of the resource is subscription", Required: []string{"subscription"}},
+	"subscriptionIn":    {Description: "the subscription ID of the resource is one of values", Required: []string{"values"}, Lists: []string{"values"}},
+	"regionIn":          {Description: "the region of the resource is one of values", Required: []string{"values"}, Lists: []string{"values"}},
+	"tagValueIn":        {Description: "the value of tag is one of values", Required: []string{"tag", "values"}, Lists: []string{"values"}},
+}
+
+// ActionSpecs lists the supported action types
+var ActionSpecs = map[string]Spec{
+	"addTag":    {Description: "sets tag to value", Required: []string{"tag", "value"}},
+	"delTag":    {Description: "deletes tag", Required: []string{"tag"}},
+	"cleanTags": {Description: "deletes all tags"},
+}
@@ -0,0 +1,140 @@
+package rules
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TemplateData is the data available to templates in action values and condition parameters,
+// for example {{ .ResourceGroup | lower }}, {{ .Tags.owner }} or {{ .Vars.costcenter }}
+type TemplateData struct {
+	ID             string
+	Name           string
+	ResourceGroup  string
+	Region         string
+	Type           string
+	Kind           string
+	SubscriptionID string
+	Tags           map[string]string // tags of the resource, missing tags are empty
+	Vars           map[string]string // vars of the rules definition
+}
+
+// templateFuncs are the functions available to templates
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	"now":   time.Now,
+	// date formats t with a Go layout, as in {{ now | date "2006-01-02" }}
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	// default returns value, or def if value is empty, as in {{ .Tags.env | default "dev" }}
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// templates keeps already parsed templates, so every template is parsed only once
+var templates sync.Map
+
+// isTemplate returns true if s contains a template action
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// Literal returns text escaped so that expanding it returns text itself. Values read from Azure, like tags of
+// resource groups, are data and must not be expanded as templates.
+func Literal(text string) string {
+	if !isTemplate(text) {
+		return text
+	}
+	return strings.ReplaceAll(text, "{{", `{{"{{"}}`)
+}
+
+// parseTemplate returns the parsed template of text
+func parseTemplate(text string) (*template.Template, error) {
+	if tmpl, ok := templates.Load(text); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse template %q", text)
+	}
+	templates.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// Expand executes text as a template with data. Text without templates is returned as it is.
+func Expand(text string, data TemplateData) (string, error) {
+	if !isTemplate(text) {
+		return text, nil
+	}
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", errors.Wrapf(err, "can't execute template %q", text)
+	}
+	return b.String(), nil
+}
+
+// expandParameters returns a copy of params with all parameters but the type expanded with data.
+// params itself is returned when it has no templates.
+func expandParameters(params map[string]string, data TemplateData) (map[string]string, error) {
+	if !hasTemplates(params) {
+		return params, nil
+	}
+	expanded := make(map[string]string, len(params))
+	for key, value := range params {
+		if key == "type" {
+			expanded[key] = value
+			continue
+		}
+		v, err := Expand(value, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parameter %s", key)
+		}
+		expanded[key] = v
+	}
+	return expanded, nil
+}
+
+// hasTemplates returns true if any parameter of params but the type is a template
+func hasTemplates(params map[string]string) bool {
+	for key, value := range params {
+		if key != "type" && isTemplate(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTemplates returns true if any parameter of the condition is a template
+func (p ConditionItem) HasTemplates() bool {
+	return hasTemplates(p)
+}
+
+// Expand returns the condition with all parameters expanded with data
+func (p ConditionItem) Expand(data TemplateData) (ConditionItem, error) {
+	return expandParameters(p, data)
+}
+
+// HasTemplates returns true if any parameter of the action is a template
+func (p ActionItem) HasTemplates() bool {
+	return hasTemplates(p)
+}
+
+// Expand returns the action with all parameters expanded with data
+func (p ActionItem) Expand(data TemplateData) (ActionItem, error) {
+	return expandParameters(p, data)
+}
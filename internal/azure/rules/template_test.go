@@ -0,0 +1,70 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	data := TemplateData{
+		Name:          "vm-1",
+		ResourceGroup: "RG-Team-Prod",
+		Region:        "westeurope",
+		Tags:          map[string]string{"owner": "team-a"},
+		Vars:          map[string]string{"costcenter": "cc-042"},
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain text", text: "team-a", want: "team-a"},
+		{name: "lower", text: "{{ .ResourceGroup | lower }}", want: "rg-team-prod"},
+		{name: "tag", text: "{{ .Tags.owner }}", want: "team-a"},
+		{name: "missing tag", text: "{{ .Tags.env }}", want: ""},
+		{name: "default", text: `{{ .Tags.env | default "dev" }}`, want: "dev"},
+		{name: "region and name", text: "{{ .Region }}/{{ .Name }}", want: "westeurope/vm-1"},
+		{name: "vars", text: "{{ .Vars.costcenter }}", want: "cc-042"},
+		{name: "date", text: `{{ now | date "2006" }}`, want: time.Now().Format("2006")},
+		{name: "unknown field", text: "{{ .Owner }}", wantErr: true},
+		{name: "unknown function", text: "{{ .Name | title }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.text, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	for _, text := range []string{"team-a", "{{ .Name }}", "a{{{b}}", "{{{{", `{{"{{"}}`, "{{ .Name | title }}"} {
+		got, err := Expand(Literal(text), TemplateData{Name: "vm-1"})
+		if err != nil || got != text {
+			t.Errorf("Expand(Literal(%q)) = %q, %v, want it unchanged", text, got, err)
+		}
+	}
+}
+
+func TestActionItem_Expand(t *testing.T) {
+	action := ActionItem{"type": "addTag", "tag": "owner-{{ .Region }}", "value": "{{ .Tags.owner | upper }}"}
+	got, err := action.Expand(TemplateData{Region: "eastus", Tags: map[string]string{"owner": "team-a"}})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	want := ActionItem{"type": "addTag", "tag": "owner-eastus", "value": "TEAM-A"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expand() = %v, want %v", got, want)
+	}
+	if action["value"] != "{{ .Tags.owner | upper }}" {
+		t.Errorf("Expand() changed the action: %v", action)
+	}
+}
@@ -0,0 +1,38 @@
+package rules
+
+// RuleTest is a test of rules on a fixture resource. Expectations which are not given are not checked.
+type RuleTest struct {
+	Name     string            `json:"name"`
+	Resource TestResource      `json:"resource"`
+	Matches  []string          `json:"matches,omitempty"` // names of the rules expected to match, in the order they are evaluated; an empty list expects no match
+	Tags     map[string]string `json:"tags,omitempty"`    // tags expected after all actions; an empty map expects no tags
+}
+
+// TestResource is a fixture resource of a rule test
+type TestResource struct {
+	ID             string            `json:"id,omitempty"` // built from the other fields when empty
+	Name           string            `json:"name"`
+	ResourceGroup  string            `json:"resourceGroup"`
+	Region         string            `json:"region,omitempty"`
+	Type           string            `json:"type,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	SubscriptionID string            `json:"subscriptionId,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+var (
+	testKeys         = []string{"name", "resource", "matches", "tags"}
+	testResourceKeys = []string{"id", "name", "resourceGroup", "region", "type", "kind", "subscriptionId", "tags"}
+)
+
+// test checks the keys of a rule test at path
+func (v *validator) test(path string, test RuleTest) {
+	v.unknownKeys(path, testKeys, false)
+	v.unknownKeys(path+".resource", testResourceKeys, false)
+	if test.Name == "" {
+		v.add(path, false, "test has no name")
+	}
+	if test.Matches == nil && test.Tags == nil {
+		v.add(path, true, "test %q expects neither matches nor tags", test.Name)
+	}
+}
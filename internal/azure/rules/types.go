@@ -3,40 +3,199 @@ package rules
 import (
 	"bytes"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"sort"
+	"strings"
 	"unicode"
 
 	"github.com/ghodss/yaml"
 
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
-// NewFromFile reads filename and returns TagRules
+// NewFromFile reads filename and the files it includes and returns the merged TagRules.
+// Included files are merged first, in the order they are listed, followed by the rules of filename.
+// Warnings found in the files are logged.
 func NewFromFile(filename string) (TagRules, error) {
-	dat, err := ioutil.ReadFile(filename)
+	rulesDef, warnings, err := LoadFile(filename)
+	logWarnings(warnings)
+	return rulesDef, err
+}
+
+// NewFromString parses rulesDef and returns TagRules. Includes are read relative to the working directory.
+func NewFromString(rulesDef string) (TagRules, error) {
+	parsed, warnings, err := LoadString(rulesDef, "rules")
+	logWarnings(warnings)
+	return parsed, err
+}
+
+// LoadFile reads and validates filename and the files it includes like NewFromFile. It returns the merged
+// TagRules and the warnings of all files; errors of a file are returned as a *ValidationError.
+func LoadFile(filename string) (TagRules, []Issue, error) {
+	l := newLoader()
+	if err := l.loadFile(filename); err != nil {
+		return TagRules{}, l.warnings, err
+	}
+	return l.merged, l.warnings, nil
+}
+
+// LoadString validates rulesDef, named file in issues, and the files it includes like LoadFile
+func LoadString(rulesDef string, file string) (TagRules, []Issue, error) {
+	parsed, warnings, err := parseRulesDefinitions(rulesDef, file)
 	if err != nil {
-		return TagRules{}, errors.Wrap(err, "error opening the file")
+		return TagRules{}, nil, err
 	}
 
-	return NewFromString(string(dat))
+	l := newLoader()
+	if err := l.include(".", parsed.Include); err != nil {
+		return TagRules{}, l.warnings, err
+	}
+	l.warnings = append(l.warnings, warnings...)
+	if err := l.merge(parsed, file); err != nil {
+		return TagRules{}, l.warnings, err
+	}
+	return l.merged, l.warnings, nil
 }
 
-// NewFromString parses rulesDef and returns TagRules
-func NewFromString(rulesDef string) (TagRules, error) {
-	return parseRulesDefinitions(rulesDef)
+// logWarnings logs warnings found in rules definitions
+func logWarnings(warnings []Issue) {
+	for _, w := range warnings {
+		log.Warn(w.String())
+	}
 }
 
+// Policies resolving conflicts between matched rules which write the same tag of a resource
+const (
+	ConflictLastWins  = "last-wins"  // the rule whose actions are applied last wins
+	ConflictFirstWins = "first-wins" // the rule whose actions are applied first wins, the lowest priority with priorities
+	ConflictPriority  = "priority"   // the rule with the higher priority wins, rules with the same priority fail
+	ConflictError     = "error"      // every conflict fails the resource
+)
+
+// ConflictPolicies lists all conflict policies
+var ConflictPolicies = []string{ConflictLastWins, ConflictFirstWins, ConflictPriority, ConflictError}
+
 // TagRules represents rules parsed from a rules definition
 type TagRules struct {
-	DryRun *bool  `json:"dryrun,omitempty"`
-	Rules  []Rule `json:"rules"`
+	DryRun    *bool             `json:"dryrun,omitempty"`
+	Conflicts string            `json:"conflicts,omitempty"` // one of ConflictPolicies, ConflictLastWins when empty
+	Include   []string          `json:"include,omitempty"`   // rule files merged before the rules, relative paths or globs
+	Vars      map[string]string `json:"vars,omitempty"`      // constants available to templates as {{ .Vars.name }}
+	Rules     []Rule            `json:"rules"`
+	Tests     []RuleTest        `json:"tests,omitempty"` // tests of the rules, run by the test command
+}
+
+// ConflictPolicy returns the conflict policy of the rules
+func (r TagRules) ConflictPolicy() string {
+	if r.Conflicts == "" {
+		return ConflictLastWins
+	}
+	return r.Conflicts
 }
 
 // Rule represnts single rule
 type Rule struct {
-	Name       string          `json:"name,omitempty"`
-	Conditions []ConditionItem `json:"conditions"`
-	Actions    []ActionItem    `json:"actions"`
+	Name       string       `json:"name,omitempty"`
+	Priority   int          `json:"priority,omitempty"` // rules with a higher priority are evaluated first and their actions applied last
+	Stop       bool         `json:"stop,omitempty"`     // if the rule matches, rules with a lower priority are not evaluated
+	Conditions []Condition  `json:"conditions"`
+	Actions    []ActionItem `json:"actions"`
+}
+
+// ByPriority returns the rules in the order they are evaluated: highest priority first and,
+// for equal priorities, in the order they are written
+func (r TagRules) ByPriority() []Rule {
+	ordered := append([]Rule{}, r.Rules...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+	return ordered
+}
+
+// Condition represents a node of the condition tree of a rule. It is either a single
+// ConditionItem or a group which combines other conditions with anyOf, allOf or not.
+type Condition struct {
+	ConditionItem
+	AnyOf []Condition
+	AllOf []Condition
+	Not   *Condition
+}
+
+// IsGroup returns true if the condition combines other conditions
+func (c Condition) IsGroup() bool {
+	return len(c.AnyOf) > 0 || len(c.AllOf) > 0 || c.Not != nil
+}
+
+// UnmarshalJSON parses either a condition group or a single condition item
+func (c *Condition) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.Wrap(err, "condition must be an object")
+	}
+
+	groups := 0
+	for _, key := range []string{"anyOf", "allOf", "not"} {
+		if _, ok := raw[key]; ok {
+			groups++
+		}
+	}
+
+	if groups == 0 {
+		item := ConditionItem{}
+		for key, v := range raw {
+			value, err := parseParameter(v)
+			if err != nil {
+				return errors.Wrapf(err, "can't unmarshal condition parameter %s", key)
+			}
+			item[key] = value
+		}
+		*c = Condition{ConditionItem: item}
+		return nil
+	}
+
+	if groups > 1 || len(raw) > 1 {
+		return errors.New("condition group must contain exactly one of anyOf, allOf or not")
+	}
+
+	var group Condition
+	if v, ok := raw["anyOf"]; ok {
+		if err := json.Unmarshal(v, &group.AnyOf); err != nil {
+			return errors.Wrap(err, "can't unmarshal anyOf")
+		}
+		if len(group.AnyOf) == 0 {
+			return errors.New("anyOf needs at least one condition")
+		}
+	}
+	if v, ok := raw["allOf"]; ok {
+		if err := json.Unmarshal(v, &group.AllOf); err != nil {
+			return errors.Wrap(err, "can't unmarshal allOf")
+		}
+		if len(group.AllOf) == 0 {
+			return errors.New("allOf needs at least one condition")
+		}
+	}
+	if v, ok := raw["not"]; ok {
+		group.Not = &Condition{}
+		if err := json.Unmarshal(v, group.Not); err != nil {
+			return errors.Wrap(err, "can't unmarshal not")
+		}
+	}
+	*c = group
+	return nil
+}
+
+// MarshalJSON writes the condition in the same form as it is read
+func (c Condition) MarshalJSON() ([]byte, error) {
+	switch {
+	case len(c.AnyOf) > 0:
+		return json.Marshal(map[string][]Condition{"anyOf": c.AnyOf})
+	case len(c.AllOf) > 0:
+		return json.Marshal(map[string][]Condition{"allOf": c.AllOf})
+	case c.Not != nil:
+		return json.Marshal(map[string]*Condition{"not": c.Not})
+	}
+	return json.Marshal(c.ConditionItem)
 }
 
 // ConditionItem represnts one condition
@@ -50,6 +209,36 @@ func (p ConditionItem) GetType() string {
 	return ""
 }
 
+// Values returns the comma separated list of values kept in parameter key
+func (p ConditionItem) Values(key string) []string {
+	var values []string
+	for _, v := range strings.Split(p[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseParameter reads a condition parameter, which is either a string or a list of strings.
+// Lists are kept as comma separated values, so their entries can't contain commas.
+func parseParameter(v json.RawMessage) (string, error) {
+	var value string
+	if err := json.Unmarshal(v, &value); err == nil {
+		return value, nil
+	}
+	var list []string
+	if err := json.Unmarshal(v, &list); err != nil {
+		return "", errors.New("parameter must be a string or a list of strings")
+	}
+	for _, entry := range list {
+		if strings.Contains(entry, ",") {
+			return "", errors.Errorf("list entry %q contains a comma", entry)
+		}
+	}
+	return strings.Join(list, ","), nil
+}
+
 // ActionItem represnts a single action
 type ActionItem map[string]string
 
@@ -63,19 +252,43 @@ func (p ActionItem) GetType() string {
 
 var jsonPrefix = []byte("{")
 
-func parseRulesDefinitions(rules string) (TagRules, error) {
+// parseRulesDefinitions parses and validates rules read from file. It returns the warnings of the
+// definition, or a *ValidationError if it has errors.
+func parseRulesDefinitions(rules string, file string) (TagRules, []Issue, error) {
 	var rulesDef TagRules
 	byt := []byte(rules)
 	if hasJSONPrefix(byt) {
 		if err := json.Unmarshal(byt, &rulesDef); err != nil {
-			return TagRules{}, errors.Wrap(err, "can't unmarshal json rules")
+			return TagRules{}, nil, errors.Wrapf(err, "can't unmarshal json rules%s", jsonErrorLine(byt, err))
 		}
 	} else {
 		if err := yaml.Unmarshal(byt, &rulesDef); err != nil {
-			return TagRules{}, errors.Wrap(err, "can't unmarshal yaml rules")
+			return TagRules{}, nil, errors.Wrap(err, "can't unmarshal yaml rules")
 		}
 	}
-	return rulesDef, nil
+
+	issues := validate(rulesDef, file, indexPositions(byt))
+	if hasErrors(issues) {
+		return TagRules{}, nil, &ValidationError{Issues: issues}
+	}
+	return rulesDef, issues, nil
+}
+
+// jsonErrorLine returns the line of a JSON syntax or type error in data, formatted as " at line N"
+func jsonErrorLine(data []byte, err error) string {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return ""
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return fmt.Sprintf(" at line %d", bytes.Count(data[:offset], []byte("\n"))+1)
 }
 
 // hasJSONPrefix returns true if the provided buffer appears to start with
@@ -59,17 +59,75 @@ rules:
 				}
 				]
 			}`
-	empty      = `{}`
-	onlyDryRun = `{"dryrun": true}`
-	wrongJSON  = `{ew2`
-	wrongYaml  = `223322`
+	yamlGroups = `
+---
+rules:
+- name: env
+  conditions:
+  - anyOf:
+    - type: tagNotExists
+      tag: env
+    - not:
+        anyOf:
+        - type: tagEqual
+          tag: env
+          value: prod
+        - type: tagEqual
+          tag: env
+          value: dev
+  actions:
+  - type: addTag
+    tag: env
+    value: dev
+`
+	jsonGroups = `{ "rules": [
+				{
+					"name": "env",
+					"conditions": [
+						{"anyOf": [
+							{"type": "tagNotExists", "tag": "env"},
+							{"not": {"anyOf": [
+								{"type": "tagEqual", "tag": "env", "value": "prod"},
+								{"type": "tagEqual", "tag": "env", "value": "dev"}
+							]}}
+						]}
+					],
+					"actions": [
+						{"type": "addTag", "tag": "env", "value": "dev"}
+					]
+				}
+				]
+			}`
+	mixedGroup   = `{"rules": [{"conditions": [{"anyOf": [{"type": "noTags"}], "not": {"type": "noTags"}}]}]}`
+	emptyGroup   = `{"rules": [{"conditions": [{"allOf": []}]}]}`
+	yamlPatterns = `
+rules:
+- name: prod groups
+  conditions:
+  - type: rgMatches
+    glob: rg-*-prod
+  - type: regionIn
+    values: [westeurope, northeurope]
+  actions:
+  - type: addTag
+    tag: env
+    value: prod
+`
+	badPattern  = `{"rules": [{"conditions": [{"type": "nameMatches", "pattern": "(unclosed"}]}]}`
+	twoPatterns = `{"rules": [{"conditions": [{"not": {"type": "nameMatches", "pattern": "a", "glob": "b"}}]}]}`
+	commaEntry  = `{"rules": [{"conditions": [{"type": "regionIn", "values": ["westeurope", "north,europe"]}]}]}`
+	scalarList  = `{"rules": [{"conditions": [{"type": "tagExists", "tag": ["env", "owner"]}]}]}`
+	empty       = `{}`
+	onlyDryRun  = `{"dryrun": true}`
+	wrongJSON   = `{ew2`
+	wrongYaml   = `223322`
 )
 
 var (
 	twoRulesWant = TagRules{Rules: []Rule{
-		{Name: "name", Conditions: []ConditionItem{
-			{"type": "tagEqual", "tag": "test", "value": "test"},
-			{"type": "tagExists", "tag": "test"},
+		{Name: "name", Conditions: []Condition{
+			{ConditionItem: ConditionItem{"type": "tagEqual", "tag": "test", "value": "test"}},
+			{ConditionItem: ConditionItem{"type": "tagExists", "tag": "test"}},
 		},
 			Actions: []ActionItem{
 				{"type": "addTag", "tag": "test", "value": "test"},
@@ -78,6 +136,67 @@ var (
 	}}
 )
 
+var groupsWant = TagRules{Rules: []Rule{
+	{Name: "env", Conditions: []Condition{
+		{AnyOf: []Condition{
+			{ConditionItem: ConditionItem{"type": "tagNotExists", "tag": "env"}},
+			{Not: &Condition{AnyOf: []Condition{
+				{ConditionItem: ConditionItem{"type": "tagEqual", "tag": "env", "value": "prod"}},
+				{ConditionItem: ConditionItem{"type": "tagEqual", "tag": "env", "value": "dev"}},
+			}}},
+		}},
+	},
+		Actions: []ActionItem{
+			{"type": "addTag", "tag": "env", "value": "dev"},
+		},
+	},
+}}
+
+var patternsWant = TagRules{Rules: []Rule{
+	{Name: "prod groups", Conditions: []Condition{
+		{ConditionItem: ConditionItem{"type": "rgMatches", "glob": "rg-*-prod"}},
+		{ConditionItem: ConditionItem{"type": "regionIn", "values": "westeurope,northeurope"}},
+	},
+		Actions: []ActionItem{
+			{"type": "addTag", "tag": "env", "value": "prod"},
+		},
+	},
+}}
+
+const yamlTemplates = `
+vars:
+  costcenter: cc-042
+rules:
+  - name: costcenter
+    conditions:
+      - type: rgMatches
+        glob: "rg-{{ .Vars.costcenter }}-*"
+    actions:
+      - type: addTag
+        tag: costcenter
+        value: "{{ .Vars.costcenter }}"
+`
+
+var templatesWant = TagRules{
+	Vars: map[string]string{"costcenter": "cc-042"},
+	Rules: []Rule{{
+		Name:       "costcenter",
+		Conditions: []Condition{{ConditionItem: ConditionItem{"type": "rgMatches", "glob": "rg-{{ .Vars.costcenter }}-*"}}},
+		Actions:    []ActionItem{{"type": "addTag", "tag": "costcenter", "value": "{{ .Vars.costcenter }}"}},
+	}},
+}
+
+const badTemplate = `
+rules:
+  - name: broken
+    conditions:
+      - type: noTags
+    actions:
+      - type: addTag
+        tag: owner
+        value: "{{ .Tags.owner "
+`
+
 var dryRunFalse = false
 var dryRunTrue = true
 
@@ -95,6 +214,17 @@ func TestNewFromString(t *testing.T) {
 		{name: "only dryrun defined", args: args{rulesDef: onlyDryRun}, want: TagRules{DryRun: &dryRunTrue}, wantErr: false},
 		{name: "one rule", args: args{rulesDef: two}, want: twoRulesWant, wantErr: false},
 		{name: "one rule yaml", args: args{rulesDef: yamlTwo}, want: twoRulesWant, wantErr: false},
+		{name: "condition groups yaml", args: args{rulesDef: yamlGroups}, want: groupsWant, wantErr: false},
+		{name: "condition groups json", args: args{rulesDef: jsonGroups}, want: groupsWant, wantErr: false},
+		{name: "group with two operators", args: args{rulesDef: mixedGroup}, want: TagRules{}, wantErr: true},
+		{name: "empty group", args: args{rulesDef: emptyGroup}, want: TagRules{}, wantErr: true},
+		{name: "patterns and lists", args: args{rulesDef: yamlPatterns}, want: patternsWant, wantErr: false},
+		{name: "invalid regular expression", args: args{rulesDef: badPattern}, want: TagRules{}, wantErr: true},
+		{name: "pattern and glob together", args: args{rulesDef: twoPatterns}, want: TagRules{}, wantErr: true},
+		{name: "list entry with a comma", args: args{rulesDef: commaEntry}, want: TagRules{}, wantErr: true},
+		{name: "list for a string parameter", args: args{rulesDef: scalarList}, want: TagRules{}, wantErr: true},
+		{name: "vars and templates", args: args{rulesDef: yamlTemplates}, want: templatesWant, wantErr: false},
+		{name: "invalid template", args: args{rulesDef: badTemplate}, want: TagRules{}, wantErr: true},
 		{name: "wrong json", args: args{rulesDef: wrongJSON}, want: TagRules{}, wantErr: true},
 		{name: "wrong yaml", args: args{rulesDef: wrongYaml}, want: TagRules{}, wantErr: true},
 	}
@@ -112,3 +242,51 @@ func TestNewFromString(t *testing.T) {
 		})
 	}
 }
+
+func TestConditionItem_Pattern(t *testing.T) {
+	tests := []struct {
+		name  string
+		cond  ConditionItem
+		input string
+		want  bool
+	}{
+		{name: "regular expression", cond: ConditionItem{"pattern": "^rg-[a-z]+-(prod|dev)$"}, input: "rg-team-dev", want: true},
+		{name: "regular expression no match", cond: ConditionItem{"pattern": "^rg-[a-z]+-(prod|dev)$"}, input: "rg-team-test", want: false},
+		{name: "glob", cond: ConditionItem{"glob": "rg-*-prod"}, input: "rg-team-prod", want: true},
+		{name: "glob ignores case", cond: ConditionItem{"glob": "rg-*-prod"}, input: "RG-Team-Prod", want: true},
+		{name: "glob is anchored", cond: ConditionItem{"glob": "rg-*"}, input: "my-rg-1", want: false},
+		{name: "glob single character", cond: ConditionItem{"glob": "vm?.example"}, input: "vm1.example", want: true},
+		{name: "glob quotes meta characters", cond: ConditionItem{"glob": "vm?.example"}, input: "vm1xexample", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := tt.cond.Pattern()
+			if err != nil {
+				t.Fatalf("Pattern() error = %v", err)
+			}
+			if got := re.MatchString(tt.input); got != tt.want {
+				t.Errorf("Pattern().MatchString(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagRules_ByPriority(t *testing.T) {
+	ruleDef := TagRules{Rules: []Rule{
+		{Name: "a"},
+		{Name: "b", Priority: 10},
+		{Name: "c", Priority: -1},
+		{Name: "d"},
+		{Name: "e", Priority: 10},
+	}}
+	var got []string
+	for _, rule := range ruleDef.ByPriority() {
+		got = append(got, rule.Name)
+	}
+	if want := []string{"b", "e", "a", "d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ByPriority() = %v, want %v", got, want)
+	}
+	if ruleDef.Rules[1].Name != "b" {
+		t.Error("ByPriority() changed the order of the rules")
+	}
+}
@@ -0,0 +1,233 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Issue is a problem found in a rules definition. Errors make the rules invalid, warnings are only reported.
+type Issue struct {
+	File    string
+	Line    int // line of the problem, 0 if unknown
+	Column  int
+	Path    string // path of the element with the problem, like rules[0].conditions[1]
+	Message string
+	Warning bool
+}
+
+// String returns the issue as file:line:column: message
+func (i Issue) String() string {
+	loc := i.File
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d:%d", i.File, i.Line, i.Column)
+	}
+	if i.Warning {
+		return loc + ": warning: " + i.Message
+	}
+	return loc + ": " + i.Message
+}
+
+// ValidationError is returned when a rules definition has errors
+type ValidationError struct {
+	Issues []Issue // errors and warnings of the definition, in the order they are written
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, issue := range e.Issues {
+		if !issue.Warning {
+			lines = append(lines, issue.String())
+		}
+	}
+	if len(lines) == 1 {
+		return "invalid rules: " + lines[0]
+	}
+	return fmt.Sprintf("invalid rules, %d errors:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+var (
+	topLevelKeys = []string{"dryrun", "conflicts", "include", "vars", "rules", "tests"}
+	ruleKeys     = []string{"name", "priority", "stop", "conditions", "actions"}
+)
+
+// validator checks a parsed rules definition against the condition and action specs
+type validator struct {
+	file   string
+	pos    positions
+	issues []Issue
+}
+
+// validate returns the issues of rulesDef read from file, sorted by their position
+func validate(rulesDef TagRules, file string, pos positions) []Issue {
+	v := &validator{file: file, pos: pos}
+	v.unknownKeys("", topLevelKeys, false)
+	if rulesDef.Conflicts != "" && !containsString(ConflictPolicies, rulesDef.Conflicts) {
+		v.add("conflicts", false, "unknown conflict policy %q, expected one of %s", rulesDef.Conflicts, strings.Join(ConflictPolicies, ", "))
+	}
+	for i, rule := range rulesDef.Rules {
+		v.rule(fmt.Sprintf("rules[%d]", i), rule)
+	}
+	for i, test := range rulesDef.Tests {
+		v.test(fmt.Sprintf("tests[%d]", i), test)
+	}
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		a, b := v.issues[i], v.issues[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return v.issues
+}
+
+func (v *validator) add(path string, warning bool, format string, args ...interface{}) {
+	pos, _ := v.pos.find(path)
+	v.issues = append(v.issues, Issue{
+		File:    v.file,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+		Warning: warning,
+	})
+}
+
+// unknownKeys reports keys of the object at path which are not in known
+func (v *validator) unknownKeys(path string, known []string, warning bool) {
+	for _, key := range v.pos.keys[path] {
+		if !containsString(known, key) {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			v.add(child, warning, "unknown key %q, expected one of %s", key, strings.Join(known, ", "))
+		}
+	}
+}
+
+func (v *validator) rule(path string, rule Rule) {
+	v.unknownKeys(path, ruleKeys, false)
+	if len(rule.Actions) == 0 {
+		v.add(path, true, "%s has no actions", ruleLabel(path, rule))
+	}
+	for i, cond := range rule.Conditions {
+		v.condition(fmt.Sprintf("%s.conditions[%d]", path, i), cond)
+	}
+	for i, action := range rule.Actions {
+		v.parameters(fmt.Sprintf("%s.actions[%d]", path, i), "action", map[string]string(action), ActionSpecs)
+	}
+}
+
+func (v *validator) condition(path string, cond Condition) {
+	for i, c := range cond.AnyOf {
+		v.condition(fmt.Sprintf("%s.anyOf[%d]", path, i), c)
+	}
+	for i, c := range cond.AllOf {
+		v.condition(fmt.Sprintf("%s.allOf[%d]", path, i), c)
+	}
+	if cond.Not != nil {
+		v.condition(path+".not", *cond.Not)
+	}
+	if !cond.IsGroup() {
+		v.parameters(path, "condition", map[string]string(cond.ConditionItem), ConditionSpecs)
+	}
+}
+
+// parameters checks the type and the parameters of a condition or action (kind) against specs
+func (v *validator) parameters(path, kind string, params map[string]string, specs map[string]Spec) {
+	typ, ok := params["type"]
+	if !ok || typ == "" {
+		v.add(path, false, "%s has no type", kind)
+		return
+	}
+	spec, ok := specs[typ]
+	if !ok {
+		v.add(path+".type", false, "unknown %s type %q, expected one of %s", kind, typ, strings.Join(specTypes(specs), ", "))
+		return
+	}
+
+	for _, name := range spec.Required {
+		if _, ok := params[name]; !ok {
+			v.add(path, false, "%s %s needs parameter %q", kind, typ, name)
+		}
+	}
+	if spec.Pattern {
+		_, hasPattern := params["pattern"]
+		_, hasGlob := params["glob"]
+		switch {
+		case hasPattern && hasGlob:
+			v.add(path+".glob", false, "%s %s takes only one of pattern or glob", kind, typ)
+		case !hasPattern && !hasGlob:
+			v.add(path, false, "%s %s needs parameter pattern or glob", kind, typ)
+		case !isTemplate(params["pattern"]) && !isTemplate(params["glob"]):
+			if _, err := ConditionItem(params).Pattern(); err != nil {
+				key := "glob"
+				if hasPattern {
+					key = "pattern"
+				}
+				v.add(path+"."+key, false, "%s %s: %s", kind, typ, err)
+			}
+		}
+	}
+
+	known := spec.params()
+	for _, name := range sortedKeys(params) {
+		if name == "type" {
+			continue
+		}
+		if !containsString(known, name) {
+			v.add(path+"."+name, true, "unknown parameter %q of %s %s", name, kind, typ)
+		} else if v.pos.lists[path+"."+name] && !containsString(spec.Lists, name) {
+			v.add(path+"."+name, false, "parameter %s of %s %s must be a string, not a list", name, kind, typ)
+		}
+		if value := params[name]; isTemplate(value) {
+			if _, err := parseTemplate(value); err != nil {
+				v.add(path+"."+name, false, "parameter %s of %s %s: %s", name, kind, typ, err)
+			}
+		}
+	}
+}
+
+// ruleLabel names a rule in messages, by its name or by its path
+func ruleLabel(path string, rule Rule) string {
+	if rule.Name != "" {
+		return fmt.Sprintf("rule %q", rule.Name)
+	}
+	return path
+}
+
+// specTypes returns the sorted types of specs
+func specTypes(specs map[string]Spec) []string {
+	types := make([]string, 0, len(specs))
+	for typ := range specs {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// hasErrors returns true if any of issues is an error
+func hasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if !issue.Warning {
+			return true
+		}
+	}
+	return false
+}
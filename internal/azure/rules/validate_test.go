@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+const invalidYaml = `
+dryrun: true
+rules:
+- name: typo
+  condtions:
+  - type: tagEqual
+    tag: env
+  actions:
+  - type: addTg
+    tag: owner
+- name: broken
+  conditions:
+  - anyOf:
+    - type: nameMatches
+      pattern: "(["
+    - type: tagEqual
+      tag: env
+      vaule: prod
+  actions:
+  - type: delTag
+    tag: legacy
+    value: yes
+`
+
+const invalidJSON = `{
+  "rules": [
+    {
+      "name": "json",
+      "conditions": [{"type": "rgMatches"}],
+      "actions": [{"type": "addTag", "tag": "owner", "value": "{{ .Tags.owner "}]
+    }
+  ]
+}`
+
+func TestLoadString_validation(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		want []string
+	}{
+		{name: "yaml", def: invalidYaml, want: []string{
+			`rules.yaml:5:3: unknown key "condtions", expected one of name, priority, stop, conditions, actions`,
+			`rules.yaml:9:5: unknown action type "addTg", expected one of addTag, cleanTags, delTag`,
+			"rules.yaml:15:7: condition nameMatches: can't compile \"([\": error parsing regexp: missing closing ]: `[`",
+			`rules.yaml:16:7: condition tagEqual needs parameter "value"`,
+			`rules.yaml:18:7: warning: unknown parameter "vaule" of condition tagEqual`,
+			`rules.yaml:22:5: warning: unknown parameter "value" of action delTag`,
+		}},
+		{name: "json", def: invalidJSON, want: []string{
+			`rules.yaml:5:22: condition rgMatches needs parameter pattern or glob`,
+			`rules.yaml:6:54: parameter value of action addTag: can't parse template "{{ .Tags.owner ": template: :1: unclosed action`,
+		}},
+		{name: "list parameter", def: "rules:\n- conditions:\n  - type: tagExists\n    tag: [env, owner]\n  actions:\n  - type: cleanTags\n", want: []string{
+			`rules.yaml:4:5: parameter tag of condition tagExists must be a string, not a list`,
+		}},
+		{name: "conflict policy", def: "conflicts: newest\nrules: []\n", want: []string{
+			`rules.yaml:1:1: unknown conflict policy "newest", expected one of last-wins, first-wins, priority, error`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadString(tt.def, "rules.yaml")
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("LoadString() error = %v, want a validation error", err)
+			}
+			var got []string
+			for _, issue := range verr.Issues {
+				got = append(got, issue.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadString() issues =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+const invalidTests = `
+rules: []
+tests:
+- name: typo
+  resource:
+    name: vm-1
+    resourcegroup: rg
+  match: [owner]
+- resource:
+    name: vm-2
+  tags: {}
+`
+
+func TestLoadString_tests(t *testing.T) {
+	_, _, err := LoadString(invalidTests, "rules.yaml")
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("LoadString() error = %v, want a validation error", err)
+	}
+	var got []string
+	for _, issue := range verr.Issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`rules.yaml:4:3: warning: test "typo" expects neither matches nor tags`,
+		`rules.yaml:7:5: unknown key "resourcegroup", expected one of id, name, resourceGroup, region, type, kind, subscriptionId, tags`,
+		`rules.yaml:8:3: unknown key "match", expected one of name, resource, matches, tags`,
+		`rules.yaml:9:3: test has no name`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadString() issues =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestLoadString_warnings(t *testing.T) {
+	_, warnings, err := LoadString("rules:\n- name: empty\n  conditions:\n  - type: noTags\n", "rules.yaml")
+	if err != nil {
+		t.Fatalf("LoadString() error = %v", err)
+	}
+	want := []Issue{{File: "rules.yaml", Line: 2, Column: 3, Path: "rules[0]", Message: `rule "empty" has no actions`, Warning: true}}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Errorf("LoadString() warnings = %v, want %v", warnings, want)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	got, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema() error = %v", err)
+	}
+	published, err := ioutil.ReadFile("../../../rules.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(got, '\n'), published) {
+		t.Error("rules.schema.json is out of date, update it with: tagmanager validate --schema > rules.schema.json")
+	}
+}
@@ -0,0 +1,162 @@
+package azure
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+// fixtureSubscription is the subscription of fixture resources which do not set any
+const fixtureSubscription = "00000000-0000-0000-0000-000000000000"
+
+// RuleTestResult is the outcome of a rule test
+type RuleTestResult struct {
+	Name     string
+	Matched  []string          // names of the matched rules, in the order they are evaluated
+	Tags     map[string]string // tags after all actions
+	Failures []string          // differences from the expectations of the test
+}
+
+// Passed returns true if the test met all its expectations
+func (r RuleTestResult) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// TestRules evaluates the rules of the tagger on the fixture resource of every test and applies the actions
+// of the matched rules in memory, without connecting to Azure. The matched rules and the resulting tags
+// are compared with the expectations of the test.
+func (t *Tagger) TestRules(tests []rules.RuleTest) []RuleTestResult {
+	results := make([]RuleTestResult, 0, len(tests))
+	for _, test := range tests {
+		results = append(results, t.testRule(test))
+	}
+	return results
+}
+
+func (t *Tagger) testRule(test rules.RuleTest) RuleTestResult {
+	result := RuleTestResult{Name: test.Name, Matched: []string{}}
+	resource := fixtureResource(test.Resource)
+
+	// matchingRules returns the rules in the order their actions are applied, they are reported in evaluation order
+	matching := t.matchingRules(&resource)
+	evaluated := append([]rules.Rule{}, matching...)
+	sort.SliceStable(evaluated, func(i, j int) bool {
+		return evaluated[i].Priority > evaluated[j].Priority
+	})
+	for _, rule := range evaluated {
+		result.Matched = append(result.Matched, rule.Name)
+	}
+	if _, _, err := t.applyActions(&resource, matching); err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("actions failed: %s", err))
+	}
+	result.Tags = make(map[string]string, len(resource.Tags))
+	for k, v := range resource.Tags {
+		result.Tags[k] = tagValue(v)
+	}
+
+	if test.Matches != nil && !reflect.DeepEqual(result.Matched, test.Matches) {
+		result.Failures = append(result.Failures, fmt.Sprintf("matched rules [%s], want [%s]",
+			strings.Join(result.Matched, ", "), strings.Join(test.Matches, ", ")))
+	}
+	if test.Tags != nil {
+		result.Failures = append(result.Failures, tagFailures(result.Tags, test.Tags)...)
+	}
+	return result
+}
+
+// fixtureResource returns the resource of a rule test fixture, with an ID built from its fields if it has none
+func fixtureResource(f rules.TestResource) Resource {
+	subscription := f.SubscriptionID
+	if subscription == "" {
+		subscription = fixtureSubscription
+	}
+	id := f.ID
+	if id == "" {
+		typ := f.Type
+		if typ == "" {
+			typ = "Microsoft.Resources/resources"
+		}
+		id = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s/%s", subscription, f.ResourceGroup, typ, f.Name)
+	}
+
+	tags := make(map[string]*string, len(f.Tags))
+	for k, v := range f.Tags {
+		value := v
+		tags[k] = &value
+	}
+
+	resource := Resource{
+		Platform:       "azure",
+		SubscriptionID: subscription,
+		ID:             id,
+		Name:           String(f.Name),
+		Region:         f.Region,
+		ResourceGroup:  String(f.ResourceGroup),
+		Tags:           tags,
+	}
+	if f.Type != "" {
+		resource.Type = String(f.Type)
+	}
+	if f.Kind != "" {
+		resource.Kind = String(f.Kind)
+	}
+	return resource
+}
+
+// tagFailures describes the differences between tags and the expected ones, sorted by tag
+func tagFailures(tags, want map[string]string) []string {
+	keys := make(map[string]bool)
+	for k := range tags {
+		keys[k] = true
+	}
+	for k := range want {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var failures []string
+	for _, k := range sorted {
+		got, has := tags[k]
+		expected, expects := want[k]
+		switch {
+		case has && !expects:
+			failures = append(failures, fmt.Sprintf("unexpected tag %s = %q", k, got))
+		case !has && expects:
+			failures = append(failures, fmt.Sprintf("missing tag %s = %q", k, expected))
+		case got != expected:
+			failures = append(failures, fmt.Sprintf("tag %s = %q, want %q", k, got, expected))
+		}
+	}
+	return failures
+}
+
+// WriteTestResults writes the outcome of every rule test to w, followed by a summary. It returns the number
+// of failed tests. If color is true, ANSI colors are used.
+func WriteTestResults(w io.Writer, results []RuleTestResult, color bool) int {
+	paint := painter(color)
+
+	failed := 0
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "%s %s\n", paint(colorGreen, "PASS"), r.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "%s %s\n", paint(colorRed, "FAIL"), r.Name)
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "\n%d test(s): %s, %s\n", len(results),
+		paint(colorGreen, fmt.Sprintf("%d passed", len(results)-failed)),
+		paint(colorRed, fmt.Sprintf("%d failed", failed)))
+	return failed
+}
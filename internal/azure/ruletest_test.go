@@ -0,0 +1,93 @@
+package azure
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+)
+
+const testedRules = `
+rules:
+- name: owner
+  conditions:
+  - type: tagNotExists
+    tag: owner
+  actions:
+  - type: addTag
+    tag: owner
+    value: "team-{{ .ResourceGroup | lower }}"
+- name: prod
+  priority: 10
+  conditions:
+  - type: rgMatches
+    glob: "*-prod"
+  actions:
+  - type: addTag
+    tag: env
+    value: prod
+tests:
+- name: prod without owner
+  resource:
+    name: vm-1
+    resourceGroup: RG-Prod
+    type: Microsoft.Compute/virtualMachines
+  matches: [prod, owner]
+  tags:
+    owner: team-rg-prod
+    env: prod
+- name: dev with owner
+  resource:
+    name: vm-2
+    resourceGroup: rg-dev
+    tags:
+      owner: me
+  matches: []
+  tags:
+    owner: you
+    env: dev
+`
+
+func TestTagger_TestRules(t *testing.T) {
+	ruleDef, err := rules.NewFromString(testedRules)
+	if err != nil {
+		t.Fatalf("NewFromString() error = %v", err)
+	}
+	tagger := NewTagger(ruleDef, &session.AzureSession{})
+
+	results := tagger.TestRules(ruleDef.Tests)
+	if len(results) != 2 {
+		t.Fatalf("TestRules() returned %d results, want 2", len(results))
+	}
+	if !results[0].Passed() {
+		t.Errorf("TestRules() %s failed: %v", results[0].Name, results[0].Failures)
+	}
+	wantFailures := []string{
+		`missing tag env = "dev"`,
+		`tag owner = "me", want "you"`,
+	}
+	if !reflect.DeepEqual(results[1].Failures, wantFailures) {
+		t.Errorf("TestRules() %s failures = %q, want %q", results[1].Name, results[1].Failures, wantFailures)
+	}
+
+	var out bytes.Buffer
+	if failed := WriteTestResults(&out, results, false); failed != 1 {
+		t.Errorf("WriteTestResults() = %d failed, want 1", failed)
+	}
+	if !strings.Contains(out.String(), "FAIL dev with owner\n    missing tag env") || !strings.Contains(out.String(), "2 test(s): 1 passed, 1 failed") {
+		t.Errorf("WriteTestResults() output:\n%s", out.String())
+	}
+}
+
+func TestFixtureResource(t *testing.T) {
+	got := fixtureResource(rules.TestResource{Name: "st1", ResourceGroup: "rg", Type: "Microsoft.Storage/storageAccounts", Tags: map[string]string{"env": "dev"}})
+	if want := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/st1"; got.ID != want {
+		t.Errorf("fixtureResource() ID = %s, want %s", got.ID, want)
+	}
+	if !equalTags(got.Tags, map[string]*string{"env": String("dev")}) {
+		t.Errorf("fixtureResource() tags = %v", got.Tags)
+	}
+}
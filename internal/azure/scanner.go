@@ -2,7 +2,9 @@ package azure
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
@@ -13,8 +15,9 @@ import (
 // ResourceGroupScanner represents resource group scanner that scans all resources in a resource group
 type ResourceGroupScanner struct {
 	Session         *session.AzureSession
-	ResourcesClient *armresources.Client
-	GroupsClient    *armresources.ResourceGroupsClient
+	ResourcesClient ResourcesAPI
+	GroupsClient    GroupsAPI
+	Workers         int // number of resource groups scanned concurrently
 }
 
 // Scanner represents generic scanner of Azure resource groups
@@ -25,6 +28,25 @@ type Scanner interface {
 	GetResourceGroupTags(string) (map[string]*string, error)
 }
 
+// PartialScanError is returned together with the scanned resources when some resource groups could not be scanned
+type PartialScanError struct {
+	Failed map[string]error // errors by name of the resource group
+}
+
+func (e *PartialScanError) Error() string {
+	groups := make([]string, 0, len(e.Failed))
+	for rg := range e.Failed {
+		groups = append(groups, rg)
+	}
+	sort.Strings(groups)
+
+	msgs := make([]string, 0, len(groups))
+	for _, rg := range groups {
+		msgs = append(msgs, fmt.Sprintf("[%s]: %s", rg, shortError(e.Failed[rg])))
+	}
+	return fmt.Sprintf("scan is partial, %d resource group(s) failed: %s", len(groups), strings.Join(msgs, ", "))
+}
+
 // String converts string v to the string pointer
 func String(v string) *string {
 	return &v
@@ -32,7 +54,12 @@ func String(v string) *string {
 
 // GetResourceGroupTags returns a map of key value tags of a reource group rg
 func (r ResourceGroupScanner) GetResourceGroupTags(rg string) (map[string]*string, error) {
-	result, err := r.GroupsClient.Get(context.Background(), rg, nil)
+	var result armresources.ResourceGroupsClientGetResponse
+	err := Retry.Do(context.Background(), "GetResourceGroup", func(ctx context.Context) error {
+		var err error
+		result, err = r.GroupsClient.Get(ctx, rg, nil)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "GetResourceGroupTags(rg=%s): Get() failed", rg)
 	}
@@ -42,11 +69,11 @@ func (r ResourceGroupScanner) GetResourceGroupTags(rg string) (map[string]*strin
 // NewResourceGroupScanner creates ResourceGroupScanner with Azure Serssion s
 func NewResourceGroupScanner(s *session.AzureSession) *ResourceGroupScanner {
 
-	resClient, _ := armresources.NewClient(s.SubscriptionID, s.Credential, nil)
+	resClient, _ := armresources.NewClient(s.SubscriptionID, s.Credential, s.ClientOptions())
 	//resClient := resources.NewClient(s.SubscriptionID)
 	//resClient.Authorizer = s.Authorizer
 
-	grClient, _ := armresources.NewResourceGroupsClient(s.SubscriptionID, s.Credential, nil)
+	grClient, _ := armresources.NewResourceGroupsClient(s.SubscriptionID, s.Credential, s.ClientOptions())
 	//grClient := resources.NewGroupsClient(s.SubscriptionID)
 	//grClient.Authorizer = s.Authorizer
 
@@ -54,29 +81,26 @@ func NewResourceGroupScanner(s *session.AzureSession) *ResourceGroupScanner {
 		Session:         s,
 		ResourcesClient: resClient,
 		GroupsClient:    grClient,
+		Workers:         DefaultWorkers,
 	}
 
 	return scanner
 }
 
-// ScanResourceGroup returns a list of resources and their tags from a resource group rg
-func (r ResourceGroupScanner) ScanResourceGroup(rg string) []Resource {
+// ScanResourceGroup returns a list of resources and their tags from a resource group rg.
+// If any page of the list fails, no resources are returned.
+func (r ResourceGroupScanner) ScanResourceGroup(rg string) ([]Resource, error) {
 	tab := make([]Resource, 0)
 
 	pager := r.ResourcesClient.NewListByResourceGroupPager(rg, nil)
 	for pager.More() {
-		resp, _ := pager.NextPage(context.Background())
+		resp, err := nextPage(context.Background(), pager)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ScanResourceGroup(rg=%s): NextPage() failed", rg)
+		}
 		if resp.ResourceListResult.Value != nil {
 			for _, resource := range resp.ResourceListResult.Value {
-				//resourceGroups = append(resourceGroups, resp.ResourceGroupListResult.Value...)
-				tab = append(tab, Resource{
-					Platform:      "azure",
-					ID:            *resource.ID,
-					Name:          resource.Name,
-					Region:        *resource.Location,
-					Tags:          resource.Tags,
-					ResourceGroup: String(rg),
-				})
+				tab = append(tab, newResource(resource, r.Session.SubscriptionID, rg))
 
 				log.Info(*resource.Name, " ", *resource.ID, " tags: ", resource.Tags)
 			}
@@ -99,35 +123,55 @@ func (r ResourceGroupScanner) ScanResourceGroup(rg string) []Resource {
 			ResourceGroup: String(rg),
 		})
 	}*/
-	return tab
+	return tab, nil
 }
 
-// GetResources retruns list of resources in resource group
-func (r ResourceGroupScanner) GetResources() ([]Resource, error) {
-	var wg sync.WaitGroup
+// newResource converts resource from the ARM list response in resource group rg of subscription to Resource
+func newResource(resource *armresources.GenericResourceExpanded, subscription, rg string) Resource {
+	return Resource{
+		Platform:       "azure",
+		SubscriptionID: subscription,
+		ID:             *resource.ID,
+		Name:           resource.Name,
+		Region:         *resource.Location,
+		Type:           resource.Type,
+		Kind:           resource.Kind,
+		Tags:           resource.Tags,
+		ResourceGroup:  String(rg),
+	}
+}
 
+// GetResources retruns list of resources in all resource groups. If some of the resource groups
+// can't be scanned, the resources of the other groups are returned with a *PartialScanError.
+func (r ResourceGroupScanner) GetResources() ([]Resource, error) {
 	groups, err := r.GetGroups()
 	if err != nil {
 		return nil, errors.Wrap(err, "GetResources(): GetGroups() failed")
 	}
 
+	scanned := make([][]Resource, len(groups))
+	failed := make([]error, len(groups))
+	runPool(r.Workers, len(groups), func(i int) bool {
+		scanned[i], failed[i] = r.ScanResourceGroup(groups[i])
+		return true
+	})
+
 	tab := make([]Resource, 0)
-	out := make(chan []Resource)
-	for _, rg := range groups {
-		wg.Add(1)
-		go func(rg string) {
-			defer wg.Done()
-			out <- r.ScanResourceGroup(rg)
-		}(rg)
-	}
-	go func() {
-		wg.Wait()
-		close(out)
-	}()
-	for s := range out {
-		tab = append(tab, s...)
+	partial := &PartialScanError{Failed: make(map[string]error)}
+	for i, s := range scanned {
+		switch {
+		case failed[i] == nil:
+			tab = append(tab, s...)
+		case isNotFound(failed[i]):
+			log.Warnf("Resource group [%s] was deleted during the scan - ignoring", groups[i])
+		default:
+			partial.Failed[groups[i]] = failed[i]
+		}
 	}
 
+	if len(partial.Failed) > 0 {
+		return tab, partial
+	}
 	return tab, nil
 }
 
@@ -140,7 +184,10 @@ func (r ResourceGroupScanner) GetGroups() ([]string, error) {
 	//var resourceGroups []*armresources.ResourceGroup
 
 	for pager.More() {
-		resp, _ := pager.NextPage(context.Background())
+		resp, err := nextPage(context.Background(), pager)
+		if err != nil {
+			return nil, errors.Wrap(err, "GetGroups(): NextPage() failed")
+		}
 		if resp.ResourceGroupListResult.Value != nil {
 			for _, resource := range resp.ResourceGroupListResult.Value {
 				//resourceGroups = append(resourceGroups, resp.ResourceGroupListResult.Value...)
@@ -169,18 +216,13 @@ func (r ResourceGroupScanner) GetResourcesByResourceGroup(rg string) ([]Resource
 
 	pager := r.ResourcesClient.NewListByResourceGroupPager(rg, nil)
 	for pager.More() {
-		resp, _ := pager.NextPage(context.Background())
+		resp, err := nextPage(context.Background(), pager)
+		if err != nil {
+			return nil, errors.Wrapf(err, "GetResourcesByResourceGroup(rg=%s): NextPage() failed", rg)
+		}
 		if resp.ResourceListResult.Value != nil {
 			for _, resource := range resp.ResourceListResult.Value {
-				//resourceGroups = append(resourceGroups, resp.ResourceGroupListResult.Value...)
-				tab = append(tab, Resource{
-					Platform:      "azure",
-					ID:            *resource.ID,
-					Name:          resource.Name,
-					Region:        *resource.Location,
-					Tags:          resource.Tags,
-					ResourceGroup: String(rg),
-				})
+				tab = append(tab, newResource(resource, r.Session.SubscriptionID, rg))
 
 				log.Info(*resource.Name, " ", *resource.ID, " tags: ", resource.Tags)
 			}
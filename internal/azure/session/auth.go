@@ -0,0 +1,159 @@
+package session
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Authentication methods of a session
+const (
+	AuthDefault           = "default"            // the chain of environment, workload identity, managed identity and Azure CLI
+	AuthClientSecret      = "client-secret"      // service principal with a client secret
+	AuthClientCertificate = "client-certificate" // service principal with a certificate
+	AuthWorkloadIdentity  = "workload-identity"  // federated token of a Kubernetes workload identity
+	AuthManagedIdentity   = "managed-identity"   // system assigned or, with a client ID, user assigned managed identity
+	AuthCLI               = "cli"                // account logged in with az login
+	AuthDeviceCode        = "device-code"        // interactive login with a device code
+)
+
+// AuthMethods lists all authentication methods
+var AuthMethods = []string{AuthDefault, AuthClientSecret, AuthClientCertificate, AuthWorkloadIdentity, AuthManagedIdentity, AuthCLI, AuthDeviceCode}
+
+// AuthConfig selects the authentication method of a session and its parameters
+type AuthConfig struct {
+	Method              string // one of AuthMethods, AuthDefault when empty
+	TenantID            string
+	ClientID            string // client ID of the service principal or of a user assigned managed identity
+	ClientSecret        string
+	CertificatePath     string // PEM or PKCS#12 file with the certificate and its private key
+	CertificatePassword string
+}
+
+// AuthConfigFromEnv returns the authentication parameters from the environment variables used by the Azure SDKs
+func AuthConfigFromEnv() AuthConfig {
+	return AuthConfig{
+		TenantID:            os.Getenv("AZURE_TENANT_ID"),
+		ClientID:            os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret:        os.Getenv("AZURE_CLIENT_SECRET"),
+		CertificatePath:     os.Getenv("AZURE_CLIENT_CERTIFICATE_PATH"),
+		CertificatePassword: os.Getenv("AZURE_CLIENT_CERTIFICATE_PASSWORD"),
+	}
+}
+
+// NewCredential creates the credential of the method selected by a in cloud conf. Failures of the credential
+// name the method, and the first token it gets is logged.
+func NewCredential(a AuthConfig, conf cloud.Configuration) (azcore.TokenCredential, error) {
+	method := strings.ToLower(strings.TrimSpace(a.Method))
+	if method == "" {
+		method = AuthDefault
+	}
+	options := azcore.ClientOptions{Cloud: conf}
+
+	var (
+		cred azcore.TokenCredential
+		err  error
+	)
+	switch method {
+	case AuthDefault:
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: options, TenantID: a.TenantID})
+	case AuthClientSecret:
+		if err = a.require(method, "AZURE_TENANT_ID", a.TenantID, "AZURE_CLIENT_ID", a.ClientID, "AZURE_CLIENT_SECRET", a.ClientSecret); err == nil {
+			cred, err = azidentity.NewClientSecretCredential(a.TenantID, a.ClientID, a.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: options})
+		}
+	case AuthClientCertificate:
+		if err = a.require(method, "AZURE_TENANT_ID", a.TenantID, "AZURE_CLIENT_ID", a.ClientID, "AZURE_CLIENT_CERTIFICATE_PATH", a.CertificatePath); err == nil {
+			cred, err = a.certificateCredential(options)
+		}
+	case AuthWorkloadIdentity:
+		cred, err = azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{ClientOptions: options, TenantID: a.TenantID, ClientID: a.ClientID})
+	case AuthManagedIdentity:
+		mi := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: options}
+		if a.ClientID != "" {
+			mi.ID = azidentity.ClientID(a.ClientID)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(mi)
+	case AuthCLI:
+		cred, err = azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: a.TenantID})
+	case AuthDeviceCode:
+		cred, err = azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{ClientOptions: options, TenantID: a.TenantID, ClientID: a.ClientID})
+	default:
+		return nil, errors.Errorf("unknown authentication method %q, use one of %s", a.Method, strings.Join(AuthMethods, ", "))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't create %s credential", method)
+	}
+
+	log.Infof("Authenticating with %s", a.describe(method))
+	return &namedCredential{name: method, credential: cred}, nil
+}
+
+// require returns an error listing the environment variables of params (pairs of name and value) which are empty
+func (a AuthConfig) require(method string, params ...string) error {
+	var missing []string
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] == "" {
+			missing = append(missing, params[i])
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("%s authentication needs %s", method, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// certificateCredential creates a client certificate credential from the certificate file of a
+func (a AuthConfig) certificateCredential(options azcore.ClientOptions) (azcore.TokenCredential, error) {
+	data, err := ioutil.ReadFile(a.CertificatePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read certificate")
+	}
+	certs, key, err := azidentity.ParseCertificates(data, []byte(a.CertificatePassword))
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't parse certificate %s", a.CertificatePath)
+	}
+	return azidentity.NewClientCertificateCredential(a.TenantID, a.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{ClientOptions: options})
+}
+
+// describe returns the method with the identity it authenticates, without any secret
+func (a AuthConfig) describe(method string) string {
+	var details []string
+	if a.TenantID != "" && method != AuthManagedIdentity {
+		details = append(details, "tenant "+a.TenantID)
+	}
+	if a.ClientID != "" && method != AuthCLI {
+		details = append(details, "client "+a.ClientID)
+	}
+	if len(details) == 0 {
+		return method
+	}
+	return method + " (" + strings.Join(details, ", ") + ")"
+}
+
+// namedCredential wraps a credential, naming its method in errors and logging its first token
+type namedCredential struct {
+	name       string
+	credential azcore.TokenCredential
+	once       sync.Once
+}
+
+// GetToken returns a token of the wrapped credential
+func (c *namedCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := c.credential.GetToken(ctx, options)
+	if err != nil {
+		return token, errors.Wrapf(err, "%s authentication failed", c.name)
+	}
+	c.once.Do(func() {
+		log.Infof("Authenticated with %s", c.name)
+	})
+	return token, nil
+}
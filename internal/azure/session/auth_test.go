@@ -0,0 +1,59 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+func TestNewCredential(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    AuthConfig
+		wantErr string
+	}{
+		{name: "client secret", auth: AuthConfig{Method: AuthClientSecret, TenantID: "tenant", ClientID: "client", ClientSecret: "secret"}},
+		{name: "client secret missing", auth: AuthConfig{Method: AuthClientSecret, ClientID: "client"}, wantErr: "client-secret authentication needs AZURE_TENANT_ID, AZURE_CLIENT_SECRET"},
+		{name: "certificate missing", auth: AuthConfig{Method: AuthClientCertificate, TenantID: "tenant", ClientID: "client"}, wantErr: "needs AZURE_CLIENT_CERTIFICATE_PATH"},
+		{name: "certificate not found", auth: AuthConfig{Method: AuthClientCertificate, TenantID: "tenant", ClientID: "client", CertificatePath: "testdata/missing.pem"}, wantErr: "can't read certificate"},
+		{name: "managed identity", auth: AuthConfig{Method: "Managed-Identity", ClientID: "client"}},
+		{name: "cli", auth: AuthConfig{Method: AuthCLI}},
+		{name: "unknown", auth: AuthConfig{Method: "password"}, wantErr: "unknown authentication method \"password\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, err := NewCredential(tt.auth, cloud.AzurePublic)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewCredential() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewCredential() error = %v", err)
+			}
+			if cred == nil {
+				t.Fatal("NewCredential() returned no credential")
+			}
+		})
+	}
+}
+
+type failingCredential struct{}
+
+func (failingCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, errors.New("no token")
+}
+
+func TestNamedCredential_GetToken(t *testing.T) {
+	cred := &namedCredential{name: AuthManagedIdentity, credential: failingCredential{}}
+	_, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{})
+	if err == nil || err.Error() != "managed-identity authentication failed: no token" {
+		t.Errorf("GetToken() error = %v", err)
+	}
+}
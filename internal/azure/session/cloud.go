@@ -0,0 +1,55 @@
+package session
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/pkg/errors"
+)
+
+// Names of the known Azure clouds, as used by AZURE_ENVIRONMENT
+const (
+	CloudPublic       = "AzurePublicCloud"
+	CloudChina        = "AzureChinaCloud"
+	CloudUSGovernment = "AzureUSGovernmentCloud"
+)
+
+// Config selects the Azure cloud of a session and how it authenticates
+type Config struct {
+	Cloud         string // name of a known cloud, the public cloud when empty
+	Endpoint      string // custom ARM endpoint, overrides the endpoint of Cloud
+	AuthorityHost string // custom Azure AD authority host, overrides the authority of Cloud
+
+	Auth AuthConfig
+}
+
+// Configuration returns the cloud configuration selected by c
+func (c Config) Configuration() (cloud.Configuration, error) {
+	var conf cloud.Configuration
+	switch strings.ToLower(strings.TrimSpace(c.Cloud)) {
+	case "", "public", "azurecloud", strings.ToLower(CloudPublic):
+		conf = cloud.AzurePublic
+	case "china", "azurechina", strings.ToLower(CloudChina):
+		conf = cloud.AzureChina
+	case "usgov", "usgovernment", "azureusgovernment", strings.ToLower(CloudUSGovernment):
+		conf = cloud.AzureGovernment
+	default:
+		return cloud.Configuration{}, errors.Errorf("unknown Azure cloud %q, use %s, %s or %s with a custom endpoint", c.Cloud, CloudPublic, CloudChina, CloudUSGovernment)
+	}
+
+	// the known configurations are shared, so they are copied before being changed
+	services := make(map[cloud.ServiceName]cloud.ServiceConfiguration, len(conf.Services))
+	for name, service := range conf.Services {
+		services[name] = service
+	}
+	conf.Services = services
+
+	if c.Endpoint != "" {
+		endpoint := strings.TrimSuffix(c.Endpoint, "/")
+		conf.Services[cloud.ResourceManager] = cloud.ServiceConfiguration{Endpoint: endpoint, Audience: endpoint}
+	}
+	if c.AuthorityHost != "" {
+		conf.ActiveDirectoryAuthorityHost = c.AuthorityHost
+	}
+	return conf, nil
+}
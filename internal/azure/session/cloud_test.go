@@ -0,0 +1,45 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+func TestConfig_Configuration(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		wantEndpoint  string
+		wantAuthority string
+		wantErr       bool
+	}{
+		{name: "default", config: Config{}, wantEndpoint: "https://management.azure.com", wantAuthority: cloud.AzurePublic.ActiveDirectoryAuthorityHost},
+		{name: "china", config: Config{Cloud: "AzureChinaCloud"}, wantEndpoint: "https://management.chinacloudapi.cn", wantAuthority: cloud.AzureChina.ActiveDirectoryAuthorityHost},
+		{name: "usgov alias", config: Config{Cloud: "usgov"}, wantEndpoint: "https://management.usgovcloudapi.net", wantAuthority: cloud.AzureGovernment.ActiveDirectoryAuthorityHost},
+		{name: "custom", config: Config{Endpoint: "https://management.local/", AuthorityHost: "https://login.local/"},
+			wantEndpoint: "https://management.local", wantAuthority: "https://login.local/"},
+		{name: "unknown", config: Config{Cloud: "mars"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := tt.config.Configuration()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Configuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := conf.Services[cloud.ResourceManager].Endpoint; got != tt.wantEndpoint {
+				t.Errorf("ARM endpoint = %s, want %s", got, tt.wantEndpoint)
+			}
+			if conf.ActiveDirectoryAuthorityHost != tt.wantAuthority {
+				t.Errorf("authority = %s, want %s", conf.ActiveDirectoryAuthorityHost, tt.wantAuthority)
+			}
+		})
+	}
+
+	if got := cloud.AzurePublic.Services[cloud.ResourceManager].Endpoint; got != "https://management.azure.com" {
+		t.Errorf("Configuration() changed the shared public cloud, endpoint = %s", got)
+	}
+}
@@ -1,7 +1,10 @@
 package session
 
 import (
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	"github.com/pkg/errors"
 )
 
@@ -9,7 +12,36 @@ import (
 type AzureSession struct {
 	SubscriptionID string
 
-	Credential *azidentity.DefaultAzureCredential
+	Credential azcore.TokenCredential
+
+	Cloud     cloud.Configuration // Azure cloud of the clients, the public cloud when empty
+	Endpoint  string              // ARM endpoint, overrides the endpoint of Cloud
+	Transport policy.Transporter  // HTTP transport of the clients, the default one when nil
+}
+
+// ForSubscription returns a copy of the session for subscription id, sharing the same credential
+func (s *AzureSession) ForSubscription(id string) *AzureSession {
+	sess := *s
+	sess.SubscriptionID = id
+	return &sess
+}
+
+// ClientOptions returns options for ARM clients created with the session. Retries of the SDK
+// are disabled, as throttled and transient failures are retried by the callers.
+func (s *AzureSession) ClientOptions() *arm.ClientOptions {
+	options := &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+			Transport: s.Transport,
+			Cloud:     s.Cloud,
+		},
+	}
+	if s.Endpoint != "" {
+		options.Cloud.Services = map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {Endpoint: s.Endpoint, Audience: s.Endpoint},
+		}
+	}
+	return options
 }
 
 // func readJSON(path string) (*map[string]interface{}, error) {
@@ -23,17 +55,25 @@ type AzureSession struct {
 // 	return &contents, nil
 // }
 
-func NewFromAzureCredential(subscriptionId string) (*AzureSession, error) {
+// NewFromAzureCredential creates new session of subscriptionId in the cloud selected by config,
+// authenticated with the method of config.Auth
+func NewFromAzureCredential(subscriptionId string, config Config) (*AzureSession, error) {
+	conf, err := config.Configuration()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a credentials object.
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	cred, err := NewCredential(config.Auth, conf)
 	if err != nil {
-		return nil, errors.Wrap(err, "Authentication failure: %+v")
+		return nil, errors.Wrap(err, "Authentication failure")
 	}
 
 	sess := AzureSession{
 		SubscriptionID: subscriptionId,
 
 		Credential: cred,
+		Cloud:      conf,
 	}
 
 	return &sess, err
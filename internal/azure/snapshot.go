@@ -0,0 +1,187 @@
+package azure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Formats of snapshot files
+const (
+	SnapshotJSON   = "json"   // a JSON array of resources
+	SnapshotNDJSON = "ndjson" // one JSON resource per line
+)
+
+// SnapshotFormat returns the format of a snapshot file from its extension, SnapshotNDJSON for .ndjson and .jsonl files
+// and SnapshotJSON otherwise
+func SnapshotFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ndjson", ".jsonl":
+		return SnapshotNDJSON
+	default:
+		return SnapshotJSON
+	}
+}
+
+// WriteSnapshot writes resources to w in format
+func WriteSnapshot(w io.Writer, resources []Resource, format string) error {
+	switch format {
+	case SnapshotJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(resources), "WriteSnapshot()")
+	case SnapshotNDJSON:
+		enc := json.NewEncoder(w)
+		for _, r := range resources {
+			if err := enc.Encode(r); err != nil {
+				return errors.Wrapf(err, "WriteSnapshot(): can't write [%s]", r.ID)
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("WriteSnapshot(): unknown format %q", format)
+	}
+}
+
+// WriteSnapshotFile writes resources to filename in format, or in the format given by the extension of filename when format is empty
+func WriteSnapshotFile(filename string, resources []Resource, format string) error {
+	if format == "" {
+		format = SnapshotFormat(filename)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrapf(err, "can't create snapshot %s", filename)
+	}
+	if err := WriteSnapshot(f, resources, format); err != nil {
+		f.Close()
+		return err
+	}
+	return errors.Wrapf(f.Close(), "can't write snapshot %s", filename)
+}
+
+// ReadSnapshot reads resources from r, either from a JSON array or from one JSON resource per line. Every resource
+// must have an ID, a missing name or resource group is taken from it.
+func ReadSnapshot(r io.Reader) ([]Resource, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadSnapshot()")
+	}
+
+	resources := make([]Resource, 0)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &resources); err != nil {
+			return nil, errors.Wrap(err, "ReadSnapshot()")
+		}
+		for i := range resources {
+			if err := completeResource(&resources[i]); err != nil {
+				return nil, errors.Wrapf(err, "ReadSnapshot(): entry %d", i+1)
+			}
+		}
+		return resources, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var resource Resource
+		if err := json.Unmarshal(text, &resource); err != nil {
+			return nil, errors.Wrapf(err, "ReadSnapshot(): line %d", line)
+		}
+		if err := completeResource(&resource); err != nil {
+			return nil, errors.Wrapf(err, "ReadSnapshot(): line %d", line)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, errors.Wrap(scanner.Err(), "ReadSnapshot()")
+}
+
+// completeResource checks that resource r of a snapshot has an ID and takes its name and resource group from the ID
+// when they are missing
+func completeResource(r *Resource) error {
+	if r.ID == "" {
+		return errors.New("resource has no id")
+	}
+	if r.Name != nil && *r.Name != "" && r.ResourceGroup != nil && *r.ResourceGroup != "" {
+		return nil
+	}
+
+	details, err := ParseResourceID(r.ID)
+	if err != nil {
+		return errors.Wrapf(err, "resource %s has no name or resourceGroup", r.ID)
+	}
+	if r.Name == nil || *r.Name == "" {
+		r.Name = String(details.resourceName)
+	}
+	if r.ResourceGroup == nil || *r.ResourceGroup == "" {
+		r.ResourceGroup = String(details.resourceGroup)
+	}
+	return nil
+}
+
+// SnapshotScanner scans resources saved in a snapshot file instead of Azure, so it needs no credentials
+type SnapshotScanner struct {
+	Resources []Resource
+}
+
+// NewSnapshotScanner creates SnapshotScanner with resources read from filename
+func NewSnapshotScanner(filename string) (*SnapshotScanner, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open snapshot %s", filename)
+	}
+	defer f.Close()
+
+	resources, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read snapshot %s", filename)
+	}
+	return &SnapshotScanner{Resources: resources}, nil
+}
+
+// GetResources returns all resources of the snapshot
+func (s SnapshotScanner) GetResources() ([]Resource, error) {
+	return s.Resources, nil
+}
+
+// GetResourcesByResourceGroup returns resources of the snapshot in a resource group rg
+func (s SnapshotScanner) GetResourcesByResourceGroup(rg string) ([]Resource, error) {
+	tab := make([]Resource, 0)
+	for _, r := range s.Resources {
+		if r.ResourceGroup != nil && strings.EqualFold(*r.ResourceGroup, rg) {
+			tab = append(tab, r)
+		}
+	}
+	return tab, nil
+}
+
+// GetGroups returns names of resource groups of the resources in the snapshot
+func (s SnapshotScanner) GetGroups() ([]string, error) {
+	seen := make(map[string]bool)
+	tab := make([]string, 0)
+	for _, r := range s.Resources {
+		if r.ResourceGroup != nil && !seen[strings.ToLower(*r.ResourceGroup)] {
+			seen[strings.ToLower(*r.ResourceGroup)] = true
+			tab = append(tab, *r.ResourceGroup)
+		}
+	}
+	sort.Strings(tab)
+	return tab, nil
+}
+
+// GetResourceGroupTags always fails, as snapshots keep only resources and not their resource groups
+func (s SnapshotScanner) GetResourceGroupTags(rg string) (map[string]*string, error) {
+	return nil, errors.Errorf("GetResourceGroupTags(rg=%s): tags of resource groups are not saved in snapshots", rg)
+}
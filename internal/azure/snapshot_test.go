@@ -0,0 +1,139 @@
+package azure
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+func TestSnapshotFormat(t *testing.T) {
+	tests := map[string]string{
+		"inventory.json":   SnapshotJSON,
+		"inventory.ndjson": SnapshotNDJSON,
+		"inventory.JSONL":  SnapshotNDJSON,
+		"inventory":        SnapshotJSON,
+	}
+	for filename, want := range tests {
+		if got := SnapshotFormat(filename); got != want {
+			t.Errorf("SnapshotFormat(%s) = %s, want %s", filename, got, want)
+		}
+	}
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	resources := []Resource{conditionResource, {Platform: "azure", ID: "/subscriptions/1/resourceGroups/rg/providers/a/b/c", Name: String("c"), ResourceGroup: String("rg")}}
+
+	for _, format := range []string{SnapshotJSON, SnapshotNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteSnapshot(&buf, resources, format); err != nil {
+				t.Fatalf("WriteSnapshot() error = %v", err)
+			}
+			got, err := ReadSnapshot(&buf)
+			if err != nil {
+				t.Fatalf("ReadSnapshot() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, resources) {
+				t.Errorf("ReadSnapshot() = %+v, want %+v", got, resources)
+			}
+		})
+	}
+
+	if err := WriteSnapshot(&bytes.Buffer{}, resources, "csv"); err == nil {
+		t.Error("WriteSnapshot() with unknown format succeeded")
+	}
+}
+
+func TestReadSnapshot_invalidLine(t *testing.T) {
+	_, err := ReadSnapshot(bytes.NewBufferString("{\"id\": \"/subscriptions/1/resourceGroups/rg/providers/a/b/c\"}\n\n{\"id\": \n"))
+	if err == nil || !bytes.Contains([]byte(err.Error()), []byte("line 3")) {
+		t.Errorf("ReadSnapshot() error = %v, want error on line 3", err)
+	}
+}
+
+func TestReadSnapshot_incompleteResource(t *testing.T) {
+	got, err := ReadSnapshot(bytes.NewBufferString(`{"id": "/subscriptions/1/resourceGroups/rg-a/providers/Microsoft.Storage/storageAccounts/sa", "tags": {}}`))
+	if err != nil {
+		t.Fatalf("ReadSnapshot() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name == nil || *got[0].Name != "sa" || got[0].ResourceGroup == nil || *got[0].ResourceGroup != "rg-a" {
+		t.Errorf("ReadSnapshot() = %+v, want name and resource group taken from the ID", got)
+	}
+
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"no id":          {input: "{\"id\": \"/subscriptions/1/resourceGroups/rg/providers/a/b/c\"}\n{\"name\": \"vm\", \"resourceGroup\": \"rg\"}\n", want: "line 2: resource has no id"},
+		"invalid id":     {input: `[{"id": "/subscriptions/1/resourceGroups/rg/providers/a/b/c"}, {"id": "vm"}]`, want: "entry 2: resource vm has no name or resourceGroup"},
+		"no group in id": {input: `{"id": "/subscriptions/1/providers/a/b/c", "name": "c"}`, want: "line 1"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ReadSnapshot(bytes.NewBufferString(tt.input))
+			if err == nil || !bytes.Contains([]byte(err.Error()), []byte(tt.want)) {
+				t.Errorf("ReadSnapshot() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotScanner(t *testing.T) {
+	scanner, err := NewSnapshotScanner("testdata/snapshot.ndjson")
+	if err != nil {
+		t.Fatalf("NewSnapshotScanner() error = %v", err)
+	}
+
+	groups, _ := scanner.GetGroups()
+	if want := []string{"rg-a", "rg-b"}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("GetGroups() = %v, want %v", groups, want)
+	}
+	res, _ := scanner.GetResourcesByResourceGroup("RG-A")
+	if len(res) != 2 {
+		t.Errorf("GetResourcesByResourceGroup() returned %d resources, want 2", len(res))
+	}
+
+	ruleDef, err := rules.NewFromString(`
+rules:
+- name: Missing env
+  conditions:
+  - type: tagNotExists
+    tag: env
+  actions:
+  - type: addTag
+    tag: env
+    value: prod
+- name: Second subscription
+  conditions:
+  - type: subscriptionEqual
+    subscription: "2"
+  actions:
+  - type: delTag
+    tag: owner
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, _ := scanner.GetResources()
+	tagger := &Tagger{Rules: ruleDef, Matched: make(map[string]Matched)}
+	tagger.InitCondMap()
+	tagger.InitActionMap()
+	tagger.EvaluateRules(all)
+
+	matched := make([]string, 0)
+	for id := range tagger.Matched {
+		matched = append(matched, id)
+	}
+	sort.Strings(matched)
+	want := []string{
+		"/subscriptions/1/resourceGroups/rg-a/providers/Microsoft.Storage/storageAccounts/sa",
+		"/subscriptions/2/resourceGroups/rg-b/providers/Microsoft.Network/virtualNetworks/vnet",
+	}
+	if !reflect.DeepEqual(matched, want) {
+		t.Errorf("EvaluateRules() matched %v, want %v", matched, want)
+	}
+}
@@ -0,0 +1,154 @@
+package azure
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armsubscriptions"
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListSubscriptions returns IDs of all enabled subscriptions the credential of session s can see
+func ListSubscriptions(s *session.AzureSession) ([]string, error) {
+	client, err := armsubscriptions.NewClient(s.Credential, s.ClientOptions())
+	if err != nil {
+		return nil, errors.Wrap(err, "ListSubscriptions(): NewClient() failed")
+	}
+
+	tab := make([]string, 0)
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		resp, err := nextPage(context.Background(), pager)
+		if err != nil {
+			return nil, errors.Wrap(err, "ListSubscriptions(): NextPage() failed")
+		}
+		for _, sub := range resp.Value {
+			if sub.State != nil && *sub.State != armsubscriptions.SubscriptionStateEnabled {
+				log.Infof("Subscription [%s] is %s - ignoring", *sub.SubscriptionID, *sub.State)
+				continue
+			}
+			tab = append(tab, *sub.SubscriptionID)
+		}
+	}
+	return tab, nil
+}
+
+// ListManagementGroupSubscriptions returns IDs of all subscriptions in management group, including nested groups
+func ListManagementGroupSubscriptions(s *session.AzureSession, group string) ([]string, error) {
+	client, err := armmanagementgroups.NewClient(s.Credential, s.ClientOptions())
+	if err != nil {
+		return nil, errors.Wrap(err, "ListManagementGroupSubscriptions(): NewClient() failed")
+	}
+
+	tab := make([]string, 0)
+	pager := client.NewGetDescendantsPager(group, nil)
+	for pager.More() {
+		resp, err := nextPage(context.Background(), pager)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ListManagementGroupSubscriptions(group=%s): NextPage() failed", group)
+		}
+		for _, d := range resp.Value {
+			if d.Type != nil && strings.EqualFold(*d.Type, "/subscriptions") {
+				tab = append(tab, *d.Name)
+			}
+		}
+	}
+	return tab, nil
+}
+
+// SubscriptionOf returns the subscription ID of resource id
+func SubscriptionOf(id string) string {
+	parts := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	if len(parts) >= 2 && strings.EqualFold(parts[0], "subscriptions") {
+		return parts[1]
+	}
+	return ""
+}
+
+// SubscriptionsScanner scans resources of several subscriptions
+type SubscriptionsScanner struct {
+	Scanners []*ResourceGroupScanner // one scanner for each subscription
+}
+
+// NewSubscriptionsScanner creates SubscriptionsScanner for subscriptions, which share the credential of session s
+func NewSubscriptionsScanner(s *session.AzureSession, subscriptions []string) *SubscriptionsScanner {
+	scanner := &SubscriptionsScanner{}
+	for _, sub := range subscriptions {
+		scanner.Scanners = append(scanner.Scanners, NewResourceGroupScanner(s.ForSubscription(sub)))
+	}
+	return scanner
+}
+
+// GetResources returns resources of all subscriptions. If some of the subscriptions or resource groups can't be scanned,
+// the other resources are returned with a *PartialScanError, where failed groups are prefixed with their subscription.
+func (m SubscriptionsScanner) GetResources() ([]Resource, error) {
+	tab := make([]Resource, 0)
+	partial := &PartialScanError{Failed: make(map[string]error)}
+
+	for _, scanner := range m.Scanners {
+		sub := scanner.Session.SubscriptionID
+		log.Infof("Scanning subscription [%s]", sub)
+
+		res, err := scanner.GetResources()
+		var p *PartialScanError
+		switch {
+		case err == nil:
+		case errors.As(err, &p):
+			for rg, e := range p.Failed {
+				partial.Failed[sub+"/"+rg] = e
+			}
+		default:
+			partial.Failed[sub] = err
+		}
+		tab = append(tab, res...)
+	}
+
+	if len(partial.Failed) > 0 {
+		return tab, partial
+	}
+	return tab, nil
+}
+
+// GetResourcesByResourceGroup returns resources of resource groups named rg in all subscriptions
+func (m SubscriptionsScanner) GetResourcesByResourceGroup(rg string) ([]Resource, error) {
+	tab := make([]Resource, 0)
+	for _, scanner := range m.Scanners {
+		res, err := scanner.GetResourcesByResourceGroup(rg)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "subscription %s", scanner.Session.SubscriptionID)
+		}
+		tab = append(tab, res...)
+	}
+	return tab, nil
+}
+
+// GetGroups returns names of resource groups of all subscriptions
+func (m SubscriptionsScanner) GetGroups() ([]string, error) {
+	tab := make([]string, 0)
+	for _, scanner := range m.Scanners {
+		groups, err := scanner.GetGroups()
+		if err != nil {
+			return nil, errors.Wrapf(err, "subscription %s", scanner.Session.SubscriptionID)
+		}
+		tab = append(tab, groups...)
+	}
+	return tab, nil
+}
+
+// GetResourceGroupTags returns tags of resource group rg from the first subscription which has it
+func (m SubscriptionsScanner) GetResourceGroupTags(rg string) (map[string]*string, error) {
+	for _, scanner := range m.Scanners {
+		tags, err := scanner.GetResourceGroupTags(rg)
+		if isNotFound(err) {
+			continue
+		}
+		return tags, err
+	}
+	return nil, errors.Errorf("GetResourceGroupTags(rg=%s): resource group not found in any subscription", rg)
+}
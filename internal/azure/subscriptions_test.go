@@ -0,0 +1,20 @@
+package azure
+
+import "testing"
+
+func TestSubscriptionOf(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "/subscriptions/0000-1111/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa", want: "0000-1111"},
+		{id: "/Subscriptions/0000-1111/resourceGroups/rg", want: "0000-1111"},
+		{id: "/providers/Microsoft.Management/managementGroups/mg", want: ""},
+		{id: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := SubscriptionOf(tt.id); got != tt.want {
+			t.Errorf("SubscriptionOf(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
@@ -4,32 +4,32 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/operationsmanagement/armoperationsmanagement"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/redis/armredis"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
 	"github.com/jhidalgo3/azure-tag-manager/internal/azure/session"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 // Tagger reprents the maing tagging element
 type Tagger struct {
-	Session                   *session.AzureSession
-	Matched                   map[string]Matched
-	Rules                     rules.TagRules // list of rules
-	condMap                   condFuncMap    // map of implementation of conditions
-	actionMap                 actionFuncMap  // map of implementation of actions
-	dryRun                    bool           // if true, actions will not be executed
-	ResourcesClient           *armresources.Client
-	VirtualNetworksClient     *armnetwork.VirtualNetworksClient
-	StorageClient             *armstorage.AccountsClient
-	RedisClient               *armredis.Client
-	OperationManagementClient *armoperationsmanagement.SolutionsClient
+	Session    *session.AzureSession
+	Matched    map[string]Matched
+	Rules      rules.TagRules           // list of rules
+	condMap    condFuncMap              // map of implementation of conditions
+	actionMap  actionFuncMap            // map of implementation of actions
+	dryRun     bool                     // if true, actions will not be executed
+	keepGoing  bool                     // if true, execution continues after an action failed on a resource
+	workers    int                      // number of resources executed concurrently
+	writeRate  float64                  // tag writes per second in a subscription
+	limiters   map[string]*rate.Limiter // write limiters by subscription
+	limitersMu sync.Mutex
+	TagsClient TagsAPI // client to the tags API, used for every resource type
 }
 
 // Matched represents rules that mathc for a resource
@@ -47,25 +47,15 @@ type ActionExecution struct {
 
 // NewTagger creates tagger
 func NewTagger(ruleDef rules.TagRules, session *session.AzureSession) *Tagger {
-	//grClient := resources.NewClient(session.SubscriptionID)
-	//grClient.Authorizer = session.Authorizer
-
-	//grClient, _ := armresources.NewClient(session.SubscriptionID, session.Credential, nil)
-	grClient, _ := armresources.NewClient(session.SubscriptionID, session.Credential, nil)
-	networkClient, _ := armnetwork.NewVirtualNetworksClient(session.SubscriptionID, session.Credential, nil)
-	storageClient, _ := armstorage.NewAccountsClient(session.SubscriptionID, session.Credential, nil)
-	redisClient, _ := armredis.NewClient(session.SubscriptionID, session.Credential, nil)
-	solutionsClient, _ := armoperationsmanagement.NewSolutionsClient(session.SubscriptionID, session.Credential, nil)
+	tagsClient, _ := armresources.NewTagsClient(session.SubscriptionID, session.Credential, session.ClientOptions())
 
 	tagger := Tagger{
-		Session:                   session,
-		Rules:                     ruleDef,
-		Matched:                   make(map[string]Matched),
-		ResourcesClient:           grClient,
-		VirtualNetworksClient:     networkClient,
-		StorageClient:             storageClient,
-		RedisClient:               redisClient,
-		OperationManagementClient: solutionsClient,
+		Session:    session,
+		Rules:      ruleDef,
+		Matched:    make(map[string]Matched),
+		TagsClient: tagsClient,
+		workers:    DefaultWorkers,
+		writeRate:  DefaultWriteRate,
 	}
 
 	tagger.InitActionMap()
@@ -79,34 +69,43 @@ func (t *Tagger) DryRun() {
 	t.dryRun = true
 }
 
-// InitActionMap initializes action map with supported actions
+// ContinueOnError makes the tagger execute actions on all resources, even if some of them fail
+func (t *Tagger) ContinueOnError() {
+	t.keepGoing = true
+}
+
+// SetWorkers sets the number of resources whose actions are executed concurrently
+func (t *Tagger) SetWorkers(n int) {
+	t.workers = n
+}
+
+// SetWriteRate limits tag writes to perSecond writes per second in every subscription. Zero disables the limit.
+func (t *Tagger) SetWriteRate(perSecond float64) {
+	t.writeRate = perSecond
+}
+
+// InitActionMap initializes action map with supported actions. Actions change the tags of data in memory,
+// the result is written to Azure once all actions for a resource are applied
 func (t *Tagger) InitActionMap() {
 	t.actionMap = actionFuncMap{}
 	t.actionMap["addTag"] = func(p map[string]string, data *Resource) error {
-		err := t.createOrUpdateTag(data.ID, p["tag"], p["value"])
-		if err != nil {
-			return errors.Wrapf(err, "Action addTag failed for resource %s", data.ID)
+		if data.Tags == nil {
+			data.Tags = make(map[string]*string)
 		}
-
+		value := p["value"]
+		data.Tags[p["tag"]] = &value
 		return nil
 	}
 
 	t.actionMap["delTag"] = func(p map[string]string, data *Resource) error {
-		err := t.deleteTag(data.ID, p["tag"])
-		if err != nil {
-			return errors.Wrapf(err, "Action delTag failed for resource %s", data.ID)
-		}
+		delete(data.Tags, p["tag"])
 		return nil
 	}
 
 	t.actionMap["cleanTags"] = func(p map[string]string, data *Resource) error {
-		err := t.deleteAllTags(data.ID)
-		if err != nil {
-			return errors.Wrapf(err, "Action cleanTags failed for resource %s", data.ID)
-		}
+		data.Tags = make(map[string]*string)
 		return nil
 	}
-
 }
 
 // InitCondMap initializes conditions map with supported conditions
@@ -183,9 +182,10 @@ func (t *Tagger) InitCondMap() {
 	}
 
 	t.condMap["rgEqual"] = func(p map[string]string, data *Resource) bool {
-		log.Info(p["resourceGroup"], " == ", *data.ResourceGroup)
+		log.Debug(p["resourceGroup"], " == ", *data.ResourceGroup)
 
-		if p["resourceGroup"] == *data.ResourceGroup {
+		// resource group names are case insensitive, the graph scanner selects them so too
+		if strings.EqualFold(p["resourceGroup"], *data.ResourceGroup) {
 
 			return true
 		}
@@ -205,181 +205,292 @@ func (t *Tagger) InitCondMap() {
 		}
 		return false
 	}
-}
 
-// ExecuteActions executes all actions based on definitions of rules. It resturns list of executed actions
-func (t *Tagger) ExecuteActions() ([]ActionExecution, error) {
-	ael := make([]ActionExecution, 0)
-	for resID, matched := range t.Matched {
-		for _, rule := range matched.TagRules {
-			ae := ActionExecution{
-				ResourceID: resID,
-				RuleName:   rule.Name,
-				Actions:    rule.Actions,
-			}
-			for _, action := range rule.Actions {
-				if t.dryRun != true {
-					resource := Resource{ID: resID}
-					err := t.Execute(&resource, action)
-					if err != nil {
-						msg := fmt.Sprintf("ExecuteActions(): Execute() failed Can't execute action [%s] on [%s], [%s]\n", action.GetType(), resource.ID, err)
-						return []ActionExecution{}, errors.New(msg)
-					}
-				}
+	t.condMap["tagMatches"] = func(p map[string]string, data *Resource) bool {
+		for k := range data.Tags {
+			if matches(p, k) {
+				return true
 			}
-			ael = append(ael, ae)
 		}
+		return false
 	}
-	return ael, nil
-}
 
-// EvaluateRules iterates over all rules and resources and checks which conditions are true.
-func (t Tagger) EvaluateRules(resources []Resource) {
-	var evaled bool
+	t.condMap["tagValueMatches"] = func(p map[string]string, data *Resource) bool {
+		if tag, ok := data.Tags[p["tag"]]; ok && tag != nil {
+			return matches(p, *tag)
+		}
+		return false
+	}
 
-	for _, resource := range resources {
-		evaled = true
-		for _, y := range t.Rules.Rules {
-			for _, cond := range y.Conditions {
-				evaled = t.Eval(&resource, cond)
-				if !evaled {
-					break
-				}
-			}
+	t.condMap["nameMatches"] = func(p map[string]string, data *Resource) bool {
+		return data.Name != nil && matches(p, *data.Name)
+	}
+
+	t.condMap["rgMatches"] = func(p map[string]string, data *Resource) bool {
+		return data.ResourceGroup != nil && matches(p, *data.ResourceGroup)
+	}
 
-			if evaled {
-				if val, ok := t.Matched[resource.ID]; ok {
-					matched := Matched{Resource: resource, TagRules: append(val.TagRules, y)}
-					t.Matched[resource.ID] = matched
-				} else {
-					matched := Matched{Resource: resource, TagRules: []rules.Rule{y}}
-					t.Matched[resource.ID] = matched
-				}
+	t.condMap["typeEqual"] = func(p map[string]string, data *Resource) bool {
+		return data.Type != nil && strings.EqualFold(p["resourceType"], *data.Type)
+	}
+
+	t.condMap["typeIn"] = func(p map[string]string, data *Resource) bool {
+		if data.Type == nil {
+			return false
+		}
+		for _, v := range rules.ConditionItem(p).Values("values") {
+			if strings.EqualFold(v, *data.Type) {
+				return true
 			}
 		}
+		return false
 	}
-}
 
-func (t Tagger) deleteAllTags(id string) error {
-	apiVersion, notSupport := getAPIVersion(id)
-	if notSupport {
-		return nil
+	t.condMap["typeMatches"] = func(p map[string]string, data *Resource) bool {
+		// types are compared case insensitive, as Resource Graph returns them in lower case
+		return data.Type != nil && matchesIgnoreCase(p, *data.Type)
 	}
 
-	r, err := t.ResourcesClient.GetByID(context.Background(), id, apiVersion, nil)
-	if err != nil {
-		return errors.Wrapf(err, "deleteAllTags(id=%s): GetByID failed", id)
+	t.condMap["kindEqual"] = func(p map[string]string, data *Resource) bool {
+		return data.Kind != nil && strings.EqualFold(p["kind"], *data.Kind)
 	}
 
-	if *r.Type == "Microsoft.Network/virtualNetworks" {
-		log.Info(" Using - VirtualNetworksClient")
+	t.condMap["subscriptionEqual"] = func(p map[string]string, data *Resource) bool {
+		return strings.EqualFold(p["subscription"], data.SubscriptionID)
+	}
 
-		detail, _ := ParseResourceID(id)
+	t.condMap["subscriptionIn"] = func(p map[string]string, data *Resource) bool {
+		for _, v := range rules.ConditionItem(p).Values("values") {
+			if strings.EqualFold(v, data.SubscriptionID) {
+				return true
+			}
+		}
+		return false
+	}
 
-		t.VirtualNetworksClient.UpdateTags(context.Background(), detail.resourceGroup, detail.resourceName, armnetwork.TagsObject{
-			Tags: make(map[string]*string),
-		}, nil)
+	t.condMap["regionIn"] = func(p map[string]string, data *Resource) bool {
+		return contains(rules.ConditionItem(p).Values("values"), data.Region)
+	}
 
-	} else if *r.Type == "Microsoft.Storage/storageAccounts" {
+	t.condMap["tagValueIn"] = func(p map[string]string, data *Resource) bool {
+		if tag, ok := data.Tags[p["tag"]]; ok && tag != nil {
+			return contains(rules.ConditionItem(p).Values("values"), *tag)
+		}
+		return false
+	}
+}
 
-		log.Info(" Using - storageClient")
+// matches returns true if s matches the pattern or glob of condition p
+func matches(p map[string]string, s string) bool {
+	re, err := rules.ConditionItem(p).Pattern()
+	if err != nil {
+		log.Warnf("Condition %s has invalid pattern - ignoring: %s", rules.ConditionItem(p).GetType(), err)
+		return false
+	}
+	return re.MatchString(s)
+}
 
-		detail, _ := ParseResourceID(id)
+// matchesIgnoreCase returns true if s matches the pattern or glob of condition p, ignoring case
+func matchesIgnoreCase(p map[string]string, s string) bool {
+	re, err := rules.ConditionItem(p).PatternIgnoreCase()
+	if err != nil {
+		log.Warnf("Condition %s has invalid pattern - ignoring: %s", rules.ConditionItem(p).GetType(), err)
+		return false
+	}
+	return re.MatchString(s)
+}
 
-		t.StorageClient.Update(context.Background(), detail.resourceGroup, detail.resourceName, armstorage.AccountUpdateParameters{
-			Tags: make(map[string]*string),
-		}, nil)
+// contains returns true if s is one of values
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
 
-	} else if *r.Type == "Microsoft.OperationsManagement/solutions" {
+// MatchedIDs returns IDs of the matched resources sorted, in the order their actions are executed
+func (t *Tagger) MatchedIDs() []string {
+	ids := make([]string, 0, len(t.Matched))
+	for id := range t.Matched {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
 
-		log.Info(" Using - operationsManagement/solutions")
+// ExecuteActions executes all actions based on definitions of rules. Actions of all rules matched for a resource
+// are merged, so every resource is read and written only once. It returns the outcome for every resource.
+// Execution stops on the first failed resource, unless ContinueOnError was set.
+func (t *Tagger) ExecuteActions() (ExecutionResult, error) {
+	var (
+		result   ExecutionResult
+		firstErr error
+		mu       sync.Mutex
+	)
+
+	ids := t.MatchedIDs()
+	runPool(t.workers, len(ids), func(i int) bool {
+		resID := ids[i]
+		matched := t.Matched[resID]
+		r := ResourceResult{ResourceID: resID}
+		for _, rule := range matched.TagRules {
+			r.Executions = append(r.Executions, ActionExecution{
+				ResourceID: resID,
+				RuleName:   rule.Name,
+				Actions:    rule.Actions,
+			})
+		}
 
-		detail, _ := ParseResourceID(id)
+		if t.dryRun != true {
+			r.Changes, r.Err = t.executeResource(resID, matched)
+			if r.Err != nil {
+				r.Err = errors.Wrapf(r.Err, "can't execute actions on [%s]", resID)
+			}
+		}
 
-		t.OperationManagementClient.BeginUpdate(context.Background(), detail.resourceGroup, detail.resourceName, armoperationsmanagement.SolutionPatch{
-			Tags: make(map[string]*string),
-		}, nil)
+		mu.Lock()
+		defer mu.Unlock()
+		result.add(r)
+		if r.Err != nil && !t.keepGoing && !isUnsupported(r.Err) {
+			if firstErr == nil {
+				firstErr = errors.Wrap(r.Err, "ExecuteActions()")
+			}
+			return false
+		}
+		return true
+	})
+	result.sort()
+	return result, firstErr
+}
 
-	} else {
-		genericResource := armresources.GenericResource{
-			Tags: make(map[string]*string),
+// EvaluateRules iterates over all rules and resources and checks which conditions are true.
+func (t *Tagger) EvaluateRules(resources []Resource) {
+	for _, resource := range resources {
+		matching := t.matchingRules(&resource)
+		if len(matching) == 0 {
+			continue
 		}
+		if val, ok := t.Matched[resource.ID]; ok {
+			t.Matched[resource.ID] = Matched{Resource: resource, TagRules: append(val.TagRules, matching...)}
+		} else {
+			t.Matched[resource.ID] = Matched{Resource: resource, TagRules: matching}
+		}
+	}
+}
 
-		_, err := t.ResourcesClient.BeginUpdateByID(context.Background(), id, apiVersion, genericResource, nil)
-		if err != nil {
-			return errors.Wrapf(err, "deleteAllTags(id=%s): UpdateByID() failed", id)
+// matchingRules returns the rules whose conditions are all true for resource. Rules are evaluated by priority,
+// a matched rule with stop skips the rules with a lower priority. The rules are returned in the order their
+// actions are applied: lowest priority first, so the tags set by higher priority rules win.
+func (t *Tagger) matchingRules(resource *Resource) []rules.Rule {
+	var (
+		matching []rules.Rule
+		stop     ruleStop
+	)
+	for _, rule := range t.Rules.ByPriority() {
+		if stop.skips(rule) != nil {
+			break
+		}
+		if t.evalAll(resource, rule.Conditions) {
+			matching = append(matching, rule)
+			stop.matched(rule)
 		}
 	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].Priority < matching[j].Priority
+	})
+	return matching
+}
 
-	return nil
+// ruleStop keeps the first matched rule with stop while rules are evaluated by priority
+type ruleStop struct {
+	by *rules.Rule
 }
 
-func (t Tagger) deleteTag(id, tag string) error {
+// skips returns the matched rule with stop which skips rule, as rule has a lower priority, or nil if rule is evaluated.
+// Rules with the same priority as the stopping rule are still evaluated.
+func (s *ruleStop) skips(rule rules.Rule) *rules.Rule {
+	if s.by != nil && rule.Priority < s.by.Priority {
+		return s.by
+	}
+	return nil
+}
 
-	apiVersion, notSupport := getAPIVersion(id)
-	if notSupport {
-		log.Warn("NOT SUPPORT TO", id)
-		return nil
+// matched records that rule matched
+func (s *ruleStop) matched(rule rules.Rule) {
+	if rule.Stop && s.by == nil {
+		s.by = &rule
 	}
+}
 
-	r, err := t.ResourcesClient.GetByID(context.Background(), id, apiVersion, nil)
+// executeResource reads the tags of resource id, applies the actions of all matched rules to them
+// and writes them back with a single update if they changed. It returns the written changes
+func (t *Tagger) executeResource(id string, matched Matched) ([]TagChange, error) {
+	current, err := getTagsAtScope(context.Background(), t.TagsClient, id)
 	if err != nil {
-		return errors.Wrapf(err, "deleteTag(id=%s, tag=%s): GetByID failed", id, tag)
+		return nil, errors.Wrapf(err, "executeResource(id=%s): getTagsAtScope() failed", id)
 	}
 
-	if _, ok := r.Tags[tag]; !ok {
-		return nil
+	resource := matched.Resource
+	resource.ID = id
+	resource.Tags = copyTags(current)
+	_, conflicts, err := t.applyActions(&resource, matched.TagRules)
+	if err != nil {
+		return nil, err
 	}
+	logConflicts(id, conflicts)
 
-	delete(r.Tags, tag)
-
-	err = t.updateTags(id, r, r.Tags, apiVersion)
+	changes := diffTags(current, resource.Tags)
+	if len(changes) == 0 {
+		log.Infof("Tags of [%s] are already up to date", id)
+		return nil, nil
+	}
 
+	err = updateTagsAtScope(context.Background(), t.TagsClient, id, current, resource.Tags, t.writeLimit(id))
 	if err != nil {
-		return errors.Wrapf(err, "deleteTag(id=%s, tag=%s): UpdateByID() failed", id, tag)
+		return nil, errors.Wrapf(err, "executeResource(id=%s): updateTagsAtScope() failed", id)
 	}
-	return err
+	return changes, nil
 }
 
-func (t Tagger) createOrUpdateTag(id, tag, value string) error {
-	log.Info("-- createOrUpdateTag ")
-
-	apiVersion, notSupport := getAPIVersion(id)
-	if notSupport {
-		return nil
+// applyActions executes actions of all matchedRules in order on resource data. It returns the name of the rule
+// which changed each tag last and the conflicts between rules writing the same tag
+func (t *Tagger) applyActions(data *Resource, matchedRules []rules.Rule) (map[string]string, []TagConflict, error) {
+	run := t.newActionRun(data)
+	for i, rule := range matchedRules {
+		for _, action := range rule.Actions {
+			if _, err := run.apply(i, rule, action); err != nil {
+				return nil, nil, errors.Wrapf(err, "rule %q", rule.Name)
+			}
+		}
 	}
+	return run.origins(), run.conflicts, nil
+}
 
-	r, err := t.ResourcesClient.GetByID(context.Background(), id, apiVersion, nil)
+// Execute executes action from p in resource data
+func (t *Tagger) Execute(data *Resource, p rules.ActionItem) error {
+	expanded, err := t.expandAction(data, p)
 	if err != nil {
-		return errors.Wrap(err, "cannot get resource by id")
+		return err
 	}
+	return t.execute(data, expanded)
+}
 
-	//Force Update Tags
-	/*if _, ok := r.Tags[tag]; ok {
-		log.Warn(ok)
-		return nil
-	}*/
-
-	if r.Tags == nil {
-		r.Tags = make(map[string]*string)
+// expandAction returns action p with its templates expanded for resource data
+func (t *Tagger) expandAction(data *Resource, p rules.ActionItem) (rules.ActionItem, error) {
+	if !p.HasTemplates() {
+		return p, nil
 	}
-
-	log.Info("**** ", *r.Type)
-	r.Tags[tag] = &value
-	/*genericResource := armresources.GenericResource{
-		Tags: r.Tags,
-	}*/
-
-	err = t.updateTags(id, r, r.Tags, apiVersion)
+	expanded, err := p.Expand(t.templateData(data))
 	if err != nil {
-		return errors.Wrap(err, "cannot update resource by id")
+		return nil, errors.Wrapf(err, "Execute(action=%q)", p.GetType())
 	}
-
-	return err
+	return expanded, nil
 }
 
-// Execute executes action from p in resource data
-func (t *Tagger) Execute(data *Resource, p rules.ActionItem) error {
+// execute executes action p, whose templates are already expanded, in resource data
+func (t *Tagger) execute(data *Resource, p rules.ActionItem) error {
 	if val, ok := t.actionMap[p.GetType()]; ok {
 		err := val(p, data)
 		if err != nil {
@@ -392,87 +503,74 @@ func (t *Tagger) Execute(data *Resource, p rules.ActionItem) error {
 	return nil
 }
 
-// Eval checks if condition p is satisfied on resource data
-func (t *Tagger) Eval(data *Resource, p rules.ConditionItem) bool {
-	if val, ok := t.condMap[p.GetType()]; ok {
-		return val(p, data)
+// templateData returns the data of resource data available to templates of conditions and actions
+func (t *Tagger) templateData(data *Resource) rules.TemplateData {
+	tags := make(map[string]string, len(data.Tags))
+	for k, v := range data.Tags {
+		if v != nil {
+			tags[k] = *v
+		}
+	}
+	return rules.TemplateData{
+		ID:             data.ID,
+		Name:           stringValue(data.Name),
+		ResourceGroup:  stringValue(data.ResourceGroup),
+		Region:         data.Region,
+		Type:           stringValue(data.Type),
+		Kind:           stringValue(data.Kind),
+		SubscriptionID: data.SubscriptionID,
+		Tags:           tags,
+		Vars:           t.Rules.Vars,
 	}
-	log.Warnf("Unknown condition type %s - ignoring", p.GetType())
-	return false
 }
 
-func getAPIVersion(id string) (string, bool) {
-	var apiVersion = "2021-04-01"
-	var notSupport = false
-
-	if strings.Contains(id, "microsoft.insights") {
-		apiVersion = "2022-04-01"
-	} else if strings.Contains(id, "Microsoft.Network") {
-		apiVersion = "2022-01-01"
-	} else if strings.Contains(id, "Microsoft.EventHub") {
-		apiVersion = "2021-11-01"
-	} else if strings.Contains(id, "Microsoft.Cache/Redis") {
-		apiVersion = "2022-06-01"
-	} else if strings.Contains(id, "Microsoft.OperationsManagement") {
-		apiVersion = "2015-11-01-preview"
-	} else if strings.Contains(id, "Microsoft.Network/networkInterfaces") {
-		notSupport = true
-	} else if strings.Contains(id, "extensions/AzureNetworkWatcherExtension") {
-		notSupport = true
-	} else if strings.Contains(id, "extensions/enablevmaccess") {
-		notSupport = true
-	}
-
-	log.Info("apiVersion: ", apiVersion, "\n\t", id)
-	return apiVersion, notSupport
+// stringValue returns the string s points to, or an empty string if s is nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
-func (t *Tagger) updateTags(id string, r armresources.ClientGetByIDResponse, tags map[string]*string, apiVersion string) error {
-	var err error
-
-	if *r.Type == "Microsoft.Network/virtualNetworks" {
-		log.Info(" Using - VirtualNetworksClient")
-
-		detail, _ := ParseResourceID(id)
-
-		t.VirtualNetworksClient.UpdateTags(context.Background(), detail.resourceGroup, detail.resourceName, armnetwork.TagsObject{
-			Tags: r.Tags,
-		}, nil)
-
-	} else if *r.Type == "Microsoft.Network" {
-		//c, _ := armnetwork.NewPrivateEndpointsClient(t.Session.SubscriptionID, t.Session.Credential, nil)
-
-		//detail, _ := ParseResourceID(id)
-
-		genericResource := armresources.GenericResource{
-			Tags: r.Tags,
+// Eval checks if condition p is satisfied on resource data. Condition groups are evaluated recursively
+func (t *Tagger) Eval(data *Resource, p rules.Condition) bool {
+	switch {
+	case len(p.AnyOf) > 0:
+		for _, cond := range p.AnyOf {
+			if t.Eval(data, cond) {
+				return true
+			}
 		}
+		return false
+	case len(p.AllOf) > 0:
+		return t.evalAll(data, p.AllOf)
+	case p.Not != nil:
+		return !t.Eval(data, *p.Not)
+	}
 
-		//c.BeginCreateOrUpdate(context.Background(), detail.resourceGroup, detail.resourceName, r.GenericResource, nil)
-		_, err = t.ResourcesClient.BeginUpdateByID(context.Background(), id, apiVersion, genericResource, nil)
-	} else if *r.Type == "Microsoft.Cache/Redis" {
-
-		log.Info("Microsoft.Cache/Redis: ", apiVersion, "\n\t", id)
-		detail, _ := ParseResourceID(id)
-
-		t.RedisClient.Update(context.Background(), detail.resourceGroup, detail.resourceName, armredis.UpdateParameters{
-			Tags: r.Tags,
-		}, nil)
-
-	} else if *r.Type == "Microsoft.OperationsManagement/solutions" {
-		log.Info("OperationsManagement/solutions: ", apiVersion, "\n\t", id)
-
-		detail, _ := ParseResourceID(id)
-
-		t.OperationManagementClient.BeginUpdate(context.Background(), detail.resourceGroup, detail.resourceName, armoperationsmanagement.SolutionPatch{
-			Tags: r.Tags,
-		}, nil)
-
-	} else {
-		_, err = t.ResourcesClient.BeginUpdateByID(context.Background(), id, apiVersion, r.GenericResource, nil)
+	if val, ok := t.condMap[p.GetType()]; ok {
+		item := p.ConditionItem
+		if item.HasTemplates() {
+			var err error
+			if item, err = item.Expand(t.templateData(data)); err != nil {
+				log.Warnf("Condition %s has invalid template - ignoring: %s", p.GetType(), err)
+				return false
+			}
+		}
+		return val(item, data)
 	}
+	log.Warnf("Unknown condition type %s - ignoring", p.GetType())
+	return false
+}
 
-	return err
+// evalAll returns true if all conditions are satisfied on resource data
+func (t *Tagger) evalAll(data *Resource, conds []rules.Condition) bool {
+	for _, cond := range conds {
+		if !t.Eval(data, cond) {
+			return false
+		}
+	}
+	return true
 }
 
 // ResourceDetails contains details about an Azure resource
@@ -0,0 +1,181 @@
+package azure
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jhidalgo3/azure-tag-manager/internal/azure/rules"
+)
+
+var conditionResource = Resource{
+	SubscriptionID: "1",
+	ID:             "/subscriptions/1/resourceGroups/rg-team-prod/providers/Microsoft.Compute/virtualMachines/vm-1",
+	Name:           String("vm-1"),
+	Region:         "westeurope",
+	Type:           String("Microsoft.Compute/virtualMachines"),
+	Kind:           String("vm"),
+	ResourceGroup:  String("rg-team-prod"),
+	Tags:           map[string]*string{"env": String("prod"), "cost-center": String("cc-042")},
+}
+
+func leaf(item rules.ConditionItem) rules.Condition {
+	return rules.Condition{ConditionItem: item}
+}
+
+func TestTagger_Eval(t *testing.T) {
+	tagger := &Tagger{}
+	tagger.InitCondMap()
+
+	tests := []struct {
+		name string
+		cond rules.Condition
+		want bool
+	}{
+		{name: "tagMatches", cond: leaf(rules.ConditionItem{"type": "tagMatches", "glob": "cost-*"}), want: true},
+		{name: "tagMatches no match", cond: leaf(rules.ConditionItem{"type": "tagMatches", "pattern": "^owner$"}), want: false},
+		{name: "tagValueMatches", cond: leaf(rules.ConditionItem{"type": "tagValueMatches", "tag": "cost-center", "pattern": `^cc-\d+$`}), want: true},
+		{name: "tagValueMatches missing tag", cond: leaf(rules.ConditionItem{"type": "tagValueMatches", "tag": "owner", "glob": "*"}), want: false},
+		{name: "nameMatches", cond: leaf(rules.ConditionItem{"type": "nameMatches", "glob": "vm-*"}), want: true},
+		{name: "rgMatches", cond: leaf(rules.ConditionItem{"type": "rgMatches", "glob": "rg-*-prod"}), want: true},
+		{name: "regionIn", cond: leaf(rules.ConditionItem{"type": "regionIn", "values": "northeurope, westeurope"}), want: true},
+		{name: "regionIn no match", cond: leaf(rules.ConditionItem{"type": "regionIn", "values": "eastus"}), want: false},
+		{name: "tagValueIn", cond: leaf(rules.ConditionItem{"type": "tagValueIn", "tag": "env", "values": "prod,dev"}), want: true},
+		{name: "typeEqual ignores case", cond: leaf(rules.ConditionItem{"type": "typeEqual", "resourceType": "microsoft.compute/virtualmachines"}), want: true},
+		{name: "typeIn", cond: leaf(rules.ConditionItem{"type": "typeIn", "values": "Microsoft.Storage/storageAccounts,Microsoft.Compute/virtualMachines"}), want: true},
+		{name: "typeMatches", cond: leaf(rules.ConditionItem{"type": "typeMatches", "glob": "Microsoft.Storage/*"}), want: false},
+		{name: "typeMatches pattern ignores case", cond: leaf(rules.ConditionItem{"type": "typeMatches", "pattern": "^microsoft\\.compute/"}), want: true},
+		{name: "nameMatches pattern is case sensitive", cond: leaf(rules.ConditionItem{"type": "nameMatches", "pattern": "^VM-"}), want: false},
+		{name: "kindEqual", cond: leaf(rules.ConditionItem{"type": "kindEqual", "kind": "VM"}), want: true},
+		{name: "subscriptionEqual", cond: leaf(rules.ConditionItem{"type": "subscriptionEqual", "subscription": "1"}), want: true},
+		{name: "subscriptionIn no match", cond: leaf(rules.ConditionItem{"type": "subscriptionIn", "values": "2,3"}), want: false},
+		{name: "anyOf", cond: rules.Condition{AnyOf: []rules.Condition{
+			leaf(rules.ConditionItem{"type": "tagNotExists", "tag": "env"}),
+			leaf(rules.ConditionItem{"type": "regionEqual", "region": "westeurope"}),
+		}}, want: true},
+		{name: "allOf", cond: rules.Condition{AllOf: []rules.Condition{
+			leaf(rules.ConditionItem{"type": "tagExists", "tag": "env"}),
+			leaf(rules.ConditionItem{"type": "regionEqual", "region": "eastus"}),
+		}}, want: false},
+		{name: "not", cond: rules.Condition{Not: &rules.Condition{AnyOf: []rules.Condition{
+			leaf(rules.ConditionItem{"type": "tagEqual", "tag": "env", "value": "prod"}),
+			leaf(rules.ConditionItem{"type": "tagEqual", "tag": "env", "value": "dev"}),
+		}}}, want: false},
+		{name: "invalid template", cond: leaf(rules.ConditionItem{"type": "tagEqual", "tag": "env", "value": "{{ .ResourceGroup | trimPrefix }}"}), want: false},
+		{name: "template parameter", cond: leaf(rules.ConditionItem{"type": "rgEqual", "resourceGroup": "rg-team-{{ .Tags.env }}"}), want: true},
+		{name: "rgEqual ignores case", cond: leaf(rules.ConditionItem{"type": "rgEqual", "resourceGroup": "RG-Team-Prod"}), want: true},
+		{name: "unknown condition", cond: leaf(rules.ConditionItem{"type": "unknown"}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagger.Eval(&conditionResource, tt.cond); got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagger_applyActions(t *testing.T) {
+	tagger := &Tagger{}
+	tagger.InitActionMap()
+
+	matchedRules := []rules.Rule{
+		{Name: "clean", Actions: []rules.ActionItem{
+			{"type": "cleanTags"},
+			{"type": "addTag", "tag": "owner", "value": "team-a"},
+		}},
+		{Name: "env", Actions: []rules.ActionItem{
+			{"type": "addTag", "tag": "env", "value": "dev"},
+			{"type": "addTag", "tag": "env", "value": "prod"},
+			{"type": "delTag", "tag": "owner"},
+			{"type": "addTag", "tag": "costcenter", "value": "42"},
+		}},
+	}
+
+	resource := Resource{ID: "1", Tags: map[string]*string{"legacy": String("yes")}}
+	origins, _, err := tagger.applyActions(&resource, matchedRules)
+	if err != nil {
+		t.Fatalf("applyActions() error = %v", err)
+	}
+
+	want := map[string]*string{"env": String("prod"), "costcenter": String("42")}
+	if !equalTags(resource.Tags, want) {
+		t.Errorf("applyActions() tags = %v, want %v", resource.Tags, want)
+	}
+
+	wantOrigins := map[string]string{"legacy": "clean", "owner": "env", "env": "env", "costcenter": "env"}
+	if !reflect.DeepEqual(origins, wantOrigins) {
+		t.Errorf("applyActions() origins = %v, want %v", origins, wantOrigins)
+	}
+}
+
+func TestTagger_applyActionsTemplates(t *testing.T) {
+	tagger := &Tagger{Rules: rules.TagRules{Vars: map[string]string{"owner": "team-a"}}}
+	tagger.InitActionMap()
+
+	matchedRules := []rules.Rule{
+		{Name: "derive", Actions: []rules.ActionItem{
+			{"type": "addTag", "tag": "costcenter", "value": "{{ .Tags.cost-center }}"},
+			{"type": "addTag", "tag": "owner", "value": "{{ .Vars.owner }}"},
+			{"type": "addTag", "tag": "location", "value": "{{ .Region }}/{{ .ResourceGroup | upper }}"},
+			{"type": "addTag", "tag": "contact", "value": "{{ .Tags.owner }}@{{ .Name }}"},
+		}},
+	}
+
+	resource := conditionResource
+	resource.Tags = copyTags(conditionResource.Tags)
+	if _, _, err := tagger.applyActions(&resource, matchedRules); err == nil {
+		t.Fatal("applyActions() expected an error for an invalid template")
+	}
+
+	matchedRules[0].Actions[0]["value"] = `{{ index .Tags "cost-center" }}`
+	resource.Tags = copyTags(conditionResource.Tags)
+	if _, _, err := tagger.applyActions(&resource, matchedRules); err != nil {
+		t.Fatalf("applyActions() error = %v", err)
+	}
+	want := map[string]*string{
+		"env":         String("prod"),
+		"cost-center": String("cc-042"),
+		"costcenter":  String("cc-042"),
+		"owner":       String("team-a"),
+		"location":    String("westeurope/RG-TEAM-PROD"),
+		"contact":     String("team-a@vm-1"),
+	}
+	if !equalTags(resource.Tags, want) {
+		t.Errorf("applyActions() tags = %v, want %v", resource.Tags, want)
+	}
+}
+
+func TestTagger_specs(t *testing.T) {
+	tagger := &Tagger{}
+	tagger.InitCondMap()
+	tagger.InitActionMap()
+
+	for typ := range tagger.condMap {
+		if _, ok := rules.ConditionSpecs[typ]; !ok {
+			t.Errorf("condition %s has no spec in rules.ConditionSpecs", typ)
+		}
+	}
+	for typ := range rules.ConditionSpecs {
+		if _, ok := tagger.condMap[typ]; !ok {
+			t.Errorf("condition spec %s has no implementation", typ)
+		}
+	}
+	for typ := range tagger.actionMap {
+		if _, ok := rules.ActionSpecs[typ]; !ok {
+			t.Errorf("action %s has no spec in rules.ActionSpecs", typ)
+		}
+	}
+	for typ := range rules.ActionSpecs {
+		if _, ok := tagger.actionMap[typ]; !ok {
+			t.Errorf("action spec %s has no implementation", typ)
+		}
+	}
+}
+
+func TestTagger_MatchedIDs(t *testing.T) {
+	tagger := &Tagger{Matched: map[string]Matched{"/c": {}, "/a": {}, "/b": {}}}
+	if got, want := tagger.MatchedIDs(), []string{"/a", "/b", "/c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchedIDs() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package azure
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	log "github.com/sirupsen/logrus"
+)
+
+// copyTags returns a copy of tags, which can be changed without changing tags
+func copyTags(tags map[string]*string) map[string]*string {
+	c := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		if v == nil {
+			c[k] = nil
+			continue
+		}
+		value := *v
+		c[k] = &value
+	}
+	return c
+}
+
+// equalTags returns true if a and b have the same keys with the same values
+func equalTags(a, b map[string]*string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		w, ok := b[k]
+		if !ok || tagValue(v) != tagValue(w) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagValue returns the value of tag v, nil tags have an empty value
+func tagValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// Actions of a TagChange
+const (
+	TagAdded   = "add"
+	TagChanged = "change"
+	TagRemoved = "remove"
+)
+
+// TagChange represents a change of a single tag
+type TagChange struct {
+	Key      string  `json:"key"`
+	Action   string  `json:"action"`
+	OldValue *string `json:"oldValue,omitempty"`
+	NewValue *string `json:"newValue,omitempty"`
+	Rule     string  `json:"rule,omitempty"`
+}
+
+// diffTags returns the changes turning tags current into desired, sorted by tag key
+func diffTags(current, desired map[string]*string) []TagChange {
+	changes := make([]TagChange, 0)
+	for k, v := range desired {
+		c, ok := current[k]
+		switch {
+		case !ok:
+			changes = append(changes, TagChange{Key: k, Action: TagAdded, NewValue: v})
+		case tagValue(c) != tagValue(v):
+			changes = append(changes, TagChange{Key: k, Action: TagChanged, OldValue: c, NewValue: v})
+		}
+	}
+	for k, v := range current {
+		if _, ok := desired[k]; !ok {
+			changes = append(changes, TagChange{Key: k, Action: TagRemoved, OldValue: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// getTagsAtScope reads the tags of the resource (or any other scope) id with the tags API
+func getTagsAtScope(ctx context.Context, client TagsAPI, id string) (map[string]*string, error) {
+	var resp armresources.TagsClientGetAtScopeResponse
+	err := Retry.Do(ctx, "GetAtScope", func(ctx context.Context) error {
+		var err error
+		resp, err = client.GetAtScope(ctx, id, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Properties == nil || resp.Properties.Tags == nil {
+		return map[string]*string{}, nil
+	}
+	return resp.Properties.Tags, nil
+}
+
+// updateTagsAtScope changes the tags of the resource (or any other scope) id from current to desired
+// with a single call to the tags API. The operation depends on the change: new and changed tags are merged,
+// removed tags are deleted and a mix of both replaces the whole set. The resource itself is never written.
+// If wait is not nil, it is called before every attempt of the call, including retries, to limit the write rate.
+func updateTagsAtScope(ctx context.Context, client TagsAPI, id string, current, desired map[string]*string, wait func(ctx context.Context) error) error {
+	if wait == nil {
+		wait = func(ctx context.Context) error { return nil }
+	}
+	if len(desired) == 0 {
+		if len(current) == 0 {
+			return nil
+		}
+		log.Infof("Deleting all tags of [%s]", id)
+		return Retry.Do(ctx, "DeleteAtScope", func(ctx context.Context) error {
+			if err := wait(ctx); err != nil {
+				return err
+			}
+			_, err := client.DeleteAtScope(ctx, id, nil)
+			return err
+		})
+	}
+
+	changed := make(map[string]*string)
+	removed := make(map[string]*string)
+	for _, change := range diffTags(current, desired) {
+		if change.Action == TagRemoved {
+			removed[change.Key] = change.OldValue
+		} else {
+			changed[change.Key] = change.NewValue
+		}
+	}
+
+	var (
+		operation armresources.TagsPatchOperation
+		tags      map[string]*string
+	)
+	switch {
+	case len(changed) == 0 && len(removed) == 0:
+		return nil
+	case len(removed) == 0:
+		operation, tags = armresources.TagsPatchOperationMerge, changed
+	case len(changed) == 0:
+		operation, tags = armresources.TagsPatchOperationDelete, removed
+	default:
+		operation, tags = armresources.TagsPatchOperationReplace, desired
+	}
+
+	log.Infof("Updating tags of [%s] with operation %s", id, operation)
+	return Retry.Do(ctx, "UpdateAtScope", func(ctx context.Context) error {
+		if err := wait(ctx); err != nil {
+			return err
+		}
+		_, err := client.UpdateAtScope(ctx, id, armresources.TagsPatchResource{
+			Operation:  &operation,
+			Properties: &armresources.Tags{Tags: tags},
+		}, nil)
+		return err
+	})
+}
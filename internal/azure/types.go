@@ -1,15 +1,16 @@
 package azure
 
-//Resource represents a generic resource with name, region, id, tags and resource group
+// Resource represents a generic resource with name, region, id, tags and resource group
 type Resource struct {
-	Platform      string
-	Name          *string
-	Region        string
-	ID            string
-	Kind          *string
-	Type          *string
-	Tags          map[string]*string
-	ResourceGroup *string
+	Platform       string             `json:"platform"`
+	SubscriptionID string             `json:"subscriptionId,omitempty"`
+	Name           *string            `json:"name"`
+	Region         string             `json:"region"`
+	ID             string             `json:"id"`
+	Kind           *string            `json:"kind,omitempty"`
+	Type           *string            `json:"type,omitempty"`
+	Tags           map[string]*string `json:"tags"`
+	ResourceGroup  *string            `json:"resourceGroup"`
 }
 
 type condFuncMap map[string]func(p map[string]string, data *Resource) bool